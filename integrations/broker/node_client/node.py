@@ -6,6 +6,7 @@ Sandrun Node Client - Connects sandrun instance to broker
 import os
 import sys
 import json
+import base64
 import time
 import requests
 import tempfile
@@ -16,6 +17,9 @@ import multiprocessing
 from typing import Dict, Any, Optional
 from datetime import datetime
 
+# Prefix of the registration the node signs (matches the broker's REGISTRATION_DOMAIN)
+REGISTRATION_DOMAIN = 'sandrun-broker-register-v1'
+
 class SandrunNode:
     """Node client that polls broker and executes jobs via sandrun"""
     
@@ -23,6 +27,7 @@ class SandrunNode:
         self.broker_url = broker_url.rstrip('/')
         self.sandrun_url = sandrun_url.rstrip('/')
         self.node_id = None
+        self.node_secret = None
         self.running = False
         
         # Load configuration
@@ -37,7 +42,9 @@ class SandrunNode:
             'poll_interval': 5,
             'heartbeat_interval': 30,
             'max_concurrent_jobs': 1,
-            'timeout_buffer': 10  # Extra seconds for sandrun timeout
+            'timeout_buffer': 10,  # Extra seconds for sandrun timeout
+            'secret_file': '.sandrun_node_secret',  # Broker-issued node secret
+            'worker_key': None  # sandrun's --worker-key PEM, proves the node's identity
         }
         
         if config_file and os.path.exists(config_file):
@@ -105,22 +112,106 @@ class SandrunNode:
         
         return interpreters
     
+    def get_identity_key(self) -> Optional[str]:
+        """Get sandrun's worker public key, if it was started with --worker-key"""
+        try:
+            response = requests.get(f"{self.sandrun_url}/health", timeout=5)
+            if response.status_code == 200:
+                return response.json().get('worker_id')
+        except Exception as e:
+            print(f"Could not read worker identity: {e}")
+        return None
+    
+    def load_worker_key(self):
+        """Load the worker's Ed25519 private key and its base64 public key, if one is configured"""
+        if not self.config['worker_key']:
+            return None, None
+        # Only keyed nodes need cryptography (pip install cryptography)
+        from cryptography.hazmat.primitives import serialization
+        with open(self.config['worker_key'], 'rb') as f:
+            worker_key = serialization.load_pem_private_key(f.read(), password=None)
+        raw = worker_key.public_key().public_bytes(
+            serialization.Encoding.Raw, serialization.PublicFormat.Raw)
+        return worker_key, base64.b64encode(raw).decode()
+    
+    def sign_registration(self, worker_key, identity_key: str) -> Optional[Dict[str, str]]:
+        """Fetch a broker nonce and sign it with the worker key"""
+        try:
+            response = requests.post(
+                f"{self.broker_url}/challenge",
+                json={'identity_key': identity_key},
+                timeout=10
+            )
+            if response.status_code != 200:
+                print(f"Challenge rejected: {response.text}")
+                return None
+            nonce = response.json()['nonce']
+        except Exception as e:
+            print(f"Challenge failed: {e}")
+            return None
+        
+        message = f"{REGISTRATION_DOMAIN}:{nonce}|{self.sandrun_url}".encode()
+        return {'nonce': nonce, 'signature': base64.b64encode(worker_key.sign(message)).decode()}
+    
+    def load_node_secret(self) -> Optional[str]:
+        """Read the secret the broker issued at first registration"""
+        try:
+            with open(self.config['secret_file'], 'r') as f:
+                return f.read().strip() or None
+        except OSError:
+            return None
+    
+    def save_node_secret(self, secret: str):
+        """Persist the broker-issued secret, readable only by this user"""
+        fd = os.open(self.config['secret_file'], os.O_WRONLY | os.O_CREAT | os.O_TRUNC, 0o600)
+        with os.fdopen(fd, 'w') as f:
+            f.write(secret)
+    
     def register(self) -> bool:
         """Register with broker"""
+        registration = {
+            'endpoint': self.sandrun_url,
+            'capabilities': self.capabilities
+        }
+        
+        # A worker key gives the node a persistent identity across endpoints;
+        # the broker only accepts it with a signature made by that key
+        worker_key, identity_key = self.load_worker_key()
+        if worker_key:
+            reported = self.get_identity_key()
+            if reported and reported != identity_key:
+                print(f"worker_key does not match sandrun's worker_id {reported}")
+                return False
+            proof = self.sign_registration(worker_key, identity_key)
+            if not proof:
+                return False
+            registration.update(proof, identity_key=identity_key)
+        elif self.get_identity_key():
+            print("sandrun has a worker key but worker_key is not configured; "
+                  "registering by endpoint")
+        
+        # Proves this is the same node when re-registering under its ID
+        node_secret = self.load_node_secret()
+        if node_secret:
+            registration['node_secret'] = node_secret
+        
         try:
             response = requests.post(
                 f"{self.broker_url}/register",
-                json={
-                    'endpoint': self.sandrun_url,
-                    'capabilities': self.capabilities
-                },
+                json=registration,
                 timeout=10
             )
             
             if response.status_code == 200:
-                self.node_id = response.json()['node_id']
+                result = response.json()
+                self.node_id = result['node_id']
+                if 'node_secret' in result:
+                    self.save_node_secret(result['node_secret'])
+                self.node_secret = result.get('node_secret', node_secret)
                 print(f"Registered with broker as node {self.node_id}")
                 return True
+            if response.status_code in (401, 409):
+                print(f"Registration rejected: {response.json().get('error')}")
         except Exception as e:
             print(f"Registration failed: {e}")
         
@@ -132,11 +223,14 @@ class SandrunNode:
             return
         
         try:
-            requests.post(
+            response = requests.post(
                 f"{self.broker_url}/heartbeat",
-                json={'node_id': self.node_id},
+                json={'node_id': self.node_id, 'node_secret': self.node_secret},
                 timeout=5
             )
+            if response.status_code == 401:
+                # Secret rotated by another registration, or the broker lost us
+                self.register()
         except Exception as e:
             print(f"Heartbeat failed: {e}")
     
@@ -151,7 +245,7 @@ class SandrunNode:
         try:
             response = requests.post(
                 f"{self.broker_url}/claim",
-                json={'node_id': self.node_id},
+                json={'node_id': self.node_id, 'node_secret': self.node_secret},
                 timeout=10
             )
             
@@ -234,7 +328,8 @@ class SandrunNode:
                                 return {
                                     'output': logs.get('stdout', ''),
                                     'error': logs.get('stderr', ''),
-                                    'exit_code': 0
+                                    'exit_code': 0,
+                                    'work_units': status.get('execution_metadata', {}).get('work_units', 0)
                                 }
                         
                         elif status['status'] == 'failed':
@@ -274,10 +369,12 @@ class SandrunNode:
                 f"{self.broker_url}/complete",
                 json={
                     'node_id': self.node_id,
+                    'node_secret': self.node_secret,
                     'job_id': job_id,
                     'output': result['output'],
                     'error': result['error'],
-                    'exit_code': result['exit_code']
+                    'exit_code': result['exit_code'],
+                    'work_units': result.get('work_units', 0)
                 },
                 timeout=10
             )
@@ -5,13 +5,18 @@ Sandrun Broker Server - Simple job distribution coordinator
 
 import os
 import json
+import base64
 import sqlite3
 import hashlib
+import hmac
+import secrets
 import threading
 from datetime import datetime, timedelta
-from typing import Optional, Dict, Any
+from typing import Optional, Dict, Any, List, Tuple
 from flask import Flask, request, jsonify
 from flask_cors import CORS
+from cryptography.exceptions import InvalidSignature
+from cryptography.hazmat.primitives.asymmetric.ed25519 import Ed25519PublicKey
 
 app = Flask(__name__)
 CORS(app)
@@ -21,7 +26,23 @@ DB_PATH = os.environ.get('BROKER_DB', 'broker.db')
 JOB_TIMEOUT = int(os.environ.get('JOB_TIMEOUT', 300))  # seconds
 RESULT_TTL = int(os.environ.get('RESULT_TTL', 3600))  # seconds
 NODE_TIMEOUT = int(os.environ.get('NODE_TIMEOUT', 60))  # seconds
+ASSIGNMENT_TTL = JOB_TIMEOUT + NODE_TIMEOUT  # seconds a claimed job may go unreported
 CLEANUP_INTERVAL = 30  # seconds
+HEALTH_THRESHOLD = float(os.environ.get('HEALTH_THRESHOLD', 0.3))  # min score to claim jobs
+PENDING_DEADLINE = int(os.environ.get('PENDING_DEADLINE', 3600))  # default seconds a job may wait to start
+UNSCHEDULABLE_THRESHOLD = float(os.environ.get('UNSCHEDULABLE_THRESHOLD', 0.25))  # fraction of deadline
+HEALTH_SAMPLE = 20  # most recent finished jobs counted toward a node's success rate
+EQUIVOCATION_PENALTY = 5  # failures an equivocation counts as in a node's health
+TIMEOUT_EXIT_CODE = 124  # exit code the node client reports when sandrun never finished the job
+GPU_SECOND_WORK_UNITS = 10.0  # work units per GPU second (sandrun's ProofOfCompute weights)
+GIB_SECOND_WORK_UNITS = 0.1  # work units per GiB of memory held for a second
+DEFAULT_JOB_CORES = 1  # cores a job without cpu_cores requirements may use
+DEFAULT_JOB_MEMORY_GB = 0.5  # sandrun's default memory limit, for jobs without memory_gb
+# Work units a failure costs a node's health: a job with no requirements run to JOB_TIMEOUT
+FAILURE_WORK_UNITS = JOB_TIMEOUT * (DEFAULT_JOB_CORES + GIB_SECOND_WORK_UNITS * DEFAULT_JOB_MEMORY_GB)
+CHALLENGE_TTL = 60  # seconds a registration nonce stays valid
+QUOTE_TTL = int(os.environ.get('QUOTE_TTL', 300))  # seconds a /quote's admission holds
+REGISTRATION_DOMAIN = 'sandrun-broker-register-v1'  # prefix of the signed registration
 
 # Database initialization
 def init_db():
@@ -36,6 +57,7 @@ def init_db():
             code TEXT NOT NULL,
             interpreter TEXT DEFAULT 'python3',
             args TEXT DEFAULT '[]',
+            requirements TEXT DEFAULT '{}',
             status TEXT DEFAULT 'pending',
             node_id TEXT,
             output TEXT,
@@ -56,13 +78,393 @@ def init_db():
             last_heartbeat TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
             jobs_completed INTEGER DEFAULT 0,
             jobs_failed INTEGER DEFAULT 0,
-            active BOOLEAN DEFAULT 1
+            active BOOLEAN DEFAULT 1,
+            identity_key TEXT
         )
     ''')
-    
+
+    # One-time nonces a keyed node signs to prove it holds its worker key
+    c.execute('''
+        CREATE TABLE IF NOT EXISTS challenges (
+            nonce TEXT PRIMARY KEY,
+            identity_key TEXT NOT NULL,
+            created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+        )
+    ''')
+
+    # Admission a schedulable /quote promised, redeemed once by /submit
+    c.execute('''
+        CREATE TABLE IF NOT EXISTS quotes (
+            id TEXT PRIMARY KEY,
+            interpreter TEXT NOT NULL,
+            requirements TEXT NOT NULL,
+            created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+        )
+    ''')
+
+    # Columns added after the initial schema
+    ensure_column(c, 'nodes', 'identity_key', 'TEXT')
+    ensure_column(c, 'nodes', 'secret_hash', 'TEXT')
+    ensure_column(c, 'jobs', 'requirements', "TEXT DEFAULT '{}'")
+    ensure_column(c, 'nodes', 'work_units', 'REAL DEFAULT 0')
+    ensure_column(c, 'jobs', 'work_units', 'REAL DEFAULT 0')
+    ensure_column(c, 'jobs', 'deadline', 'INTEGER')
+    ensure_column(c, 'nodes', 'equivocations', 'INTEGER DEFAULT 0')
+    ensure_column(c, 'jobs', 'equivocated', 'INTEGER DEFAULT 0')
+    ensure_column(c, 'nodes', 'reclaimed', 'INTEGER DEFAULT 0')
+
     conn.commit()
     conn.close()
 
+def ensure_column(c, table: str, column: str, decl: str):
+    """Add a column to an existing table if an older schema lacks it"""
+    c.execute(f'PRAGMA table_info({table})')
+    if column not in [row[1] for row in c.fetchall()]:
+        c.execute(f'ALTER TABLE {table} ADD COLUMN {column} {decl}')
+
+def node_identity(data: Dict[str, Any]) -> Tuple[str, str]:
+    """
+    Persistent identity a node registers under, as (kind, value).
+
+    Nodes started with a worker key register under its public key, which
+    stays the same when the node comes back at a new endpoint (e.g. a spot
+    instance). Anonymous nodes fall back to their endpoint. Only call this
+    once identity_signature_valid() has accepted the key.
+    """
+    if data.get('identity_key'):
+        return 'key', data['identity_key']
+    return 'endpoint', data['endpoint']
+
+def derive_node_id(data: Dict[str, Any]) -> str:
+    """Node ID for a registration; the kind prefix keeps keys and endpoints apart"""
+    kind, value = node_identity(data)
+    return hashlib.sha256(f'{kind}:{value}'.encode()).hexdigest()[:16]
+
+def adopt_legacy_node(c, data: Dict[str, Any], node_id: str) -> bool:
+    """
+    Move a node registered under the old, unprefixed ID to node_id.
+
+    IDs used to hash the bare key or endpoint. Only a row of the same
+    identity kind moves, with its job history; any secret it holds still
+    has to be presented afterwards.
+    """
+    kind, value = node_identity(data)
+    legacy_id = hashlib.sha256(value.encode()).hexdigest()[:16]
+    if kind == 'key':
+        c.execute('SELECT id FROM nodes WHERE id = ? AND identity_key = ?', (legacy_id, value))
+    else:
+        c.execute('SELECT id FROM nodes WHERE id = ? AND identity_key IS NULL', (legacy_id,))
+    if not c.fetchone():
+        return False
+    c.execute('UPDATE nodes SET id = ? WHERE id = ?', (node_id, legacy_id))
+    c.execute('UPDATE jobs SET node_id = ? WHERE node_id = ?', (node_id, legacy_id))
+    return True
+
+def registration_message(nonce: str, endpoint: str) -> bytes:
+    """Bytes a keyed node signs to register: the broker's nonce, bound to its endpoint"""
+    return f'{REGISTRATION_DOMAIN}:{nonce}|{endpoint}'.encode()
+
+def identity_signature_valid(identity_key: str, message: bytes, signature: Any) -> bool:
+    """Check a base64 Ed25519 signature over message by the key identity_key encodes"""
+    if not isinstance(identity_key, str) or not isinstance(signature, str):
+        return False
+    try:
+        public_key = Ed25519PublicKey.from_public_bytes(base64.b64decode(identity_key, validate=True))
+        public_key.verify(base64.b64decode(signature, validate=True), message)
+    except (ValueError, InvalidSignature):
+        return False
+    return True
+
+def hash_node_secret(secret: str) -> str:
+    return hashlib.sha256(secret.encode()).hexdigest()
+
+def node_secret_matches(secret: Any, secret_hash: str) -> bool:
+    """Constant-time check of a presented node secret against its hash"""
+    if not secret or not isinstance(secret, str):
+        return False
+    return hmac.compare_digest(hash_node_secret(secret), secret_hash)
+
+def authenticate_node(c, data: Dict[str, Any]) -> bool:
+    """Check the caller holds the secret issued to data['node_id'] at registration"""
+    c.execute('SELECT secret_hash FROM nodes WHERE id = ?', (data.get('node_id'),))
+    node = c.fetchone()
+    return bool(node and node['secret_hash'] and
+                node_secret_matches(data.get('node_secret'), node['secret_hash']))
+
+def requirements_error(requirements: Any) -> Optional[str]:
+    """Why a job's requirements are malformed, or None if they are usable"""
+    if not isinstance(requirements, dict):
+        return 'requirements must be an object'
+    for key in ('cpu_cores', 'memory_gb'):
+        value = requirements.get(key, 0)
+        if isinstance(value, bool) or not isinstance(value, (int, float)) or value < 0:
+            return f'requirements.{key} must be a non-negative number'
+    if not isinstance(requirements.get('gpu', False), bool):
+        return 'requirements.gpu must be true or false'
+    return None
+
+def capabilities_error(capabilities: Any) -> Optional[str]:
+    """Why a node's advertised capabilities are malformed, or None if they are usable"""
+    if not isinstance(capabilities, dict):
+        return 'capabilities must be an object'
+    for key in ('cpu_cores', 'memory_gb'):
+        value = capabilities.get(key, 0)
+        if isinstance(value, bool) or not isinstance(value, (int, float)) or value < 0:
+            return f'capabilities.{key} must be a non-negative number'
+    if not isinstance(capabilities.get('gpu', False), bool):
+        return 'capabilities.gpu must be true or false'
+    interpreters = capabilities.get('interpreters', [])
+    if not isinstance(interpreters, list) or not all(isinstance(i, str) for i in interpreters):
+        return 'capabilities.interpreters must be a list of strings'
+    return None
+
+def node_satisfies(requirements: Dict[str, Any], interpreter: str,
+                   capabilities: Dict[str, Any]) -> bool:
+    """Check whether a node's capabilities cover a job's requirements"""
+    if capabilities.get('cpu_cores', 0) < requirements.get('cpu_cores', 0):
+        return False
+    if capabilities.get('memory_gb', 0) < requirements.get('memory_gb', 0):
+        return False
+    if requirements.get('gpu') and not capabilities.get('gpu'):
+        return False
+    interpreters = capabilities.get('interpreters')
+    if interpreters is not None and interpreter not in interpreters:
+        return False
+    return True
+
+def exceeds_fleet_capability(requirements: Dict[str, Any], interpreter: str,
+                             nodes: List[Dict[str, Any]]) -> Tuple[bool, str]:
+    """
+    Check a job against the largest capabilities in the live fleet.
+
+    Returns (True, reason) if no active node could ever run the job, so it
+    should be rejected rather than left pending. With no active nodes there
+    is nothing to compare against, and the job is queued as usual.
+    """
+    if not nodes:
+        return False, ''
+
+    max_cores = max(n.get('cpu_cores', 0) for n in nodes)
+    if requirements.get('cpu_cores', 0) > max_cores:
+        return True, (f"Job requires {requirements['cpu_cores']} CPU cores; "
+                      f"largest node has {max_cores}")
+
+    max_memory = max(n.get('memory_gb', 0) for n in nodes)
+    if requirements.get('memory_gb', 0) > max_memory:
+        return True, (f"Job requires {requirements['memory_gb']} GB memory; "
+                      f"largest node has {max_memory} GB")
+
+    if requirements.get('gpu') and not any(n.get('gpu') for n in nodes):
+        return True, "Job requires a GPU; no active node has one"
+
+    if not any(interpreter in n.get('interpreters', [interpreter]) for n in nodes):
+        return True, f"Job requires interpreter '{interpreter}'; no active node provides it"
+
+    if not any(node_satisfies(requirements, interpreter, n) for n in nodes):
+        return True, "No single active node meets all of the job's requirements"
+
+    return False, ''
+
+def active_node_capabilities(c) -> List[Dict[str, Any]]:
+    """Capabilities of all active nodes"""
+    c.execute('SELECT capabilities FROM nodes WHERE active = 1')
+    return [json.loads(row['capabilities']) for row in c.fetchall()]
+
+# Leaderboard metrics: how to score a node, and whether higher is better
+LEADERBOARD_METRICS = {
+    'success_rate': lambda n: n['success_rate'],
+    'work_units': lambda n: n['work_units'],
+    'avg_latency': lambda n: -n['avg_latency'],
+}
+
+def leaderboard(history: List[Dict[str, Any]], metric: str, top: int) -> List[Dict[str, Any]]:
+    """
+    Rank nodes by one metric, best first.
+
+    history has one entry per node with completed, failed, work_units and
+    avg_latency (seconds, None if unknown). Ties are broken by node ID so
+    the order never depends on query order.
+    """
+    if metric not in LEADERBOARD_METRICS:
+        raise ValueError(f"Unknown metric '{metric}'; use one of {', '.join(LEADERBOARD_METRICS)}")
+    
+    ranked = []
+    for entry in history:
+        finished = entry['completed'] + entry['failed']
+        if finished == 0 or (metric == 'avg_latency' and entry['avg_latency'] is None):
+            continue
+        ranked.append(dict(entry, success_rate=entry['completed'] / finished))
+    
+    score = LEADERBOARD_METRICS[metric]
+    ranked.sort(key=lambda n: (-score(n), n['node_id']))
+    for rank, entry in enumerate(ranked[:top], start=1):
+        entry['rank'] = rank
+    return ranked[:top]
+
+def should_expire_pending(age: float, deadline: float, requirements: Dict[str, Any],
+                          interpreter: str, fleet: List[Dict[str, Any]],
+                          threshold: float = UNSCHEDULABLE_THRESHOLD) -> Tuple[bool, str]:
+    """
+    Decide whether a pending job should be failed as unschedulable.
+
+    A job that has waited `threshold` of its deadline while no active node
+    could run it is expired early; one whose whole deadline has passed is
+    expired regardless. A job that a busy but capable node could run keeps
+    waiting until the deadline. Returns (expire, reason).
+    """
+    if age >= deadline:
+        return True, f"Unschedulable: not started within its {deadline}s deadline"
+    if age < threshold * deadline:
+        return False, ''
+    if not fleet:
+        return True, "Unschedulable: no active nodes"
+    if not any(node_satisfies(requirements, interpreter, n) for n in fleet):
+        exceeds, reason = exceeds_fleet_capability(requirements, interpreter, fleet)
+        return True, f"Unschedulable: {reason}"
+    return False, ''
+
+def expire_unschedulable_jobs(c) -> int:
+    """Fail pending jobs that should_expire_pending() gives up on"""
+    fleet = active_node_capabilities(c)
+    c.execute('''
+        SELECT id, interpreter, requirements, deadline,
+               (julianday('now') - julianday(created_at)) * 86400 AS age
+        FROM jobs WHERE status = 'pending'
+    ''')
+    expired = 0
+    for job in c.fetchall():
+        expire, reason = should_expire_pending(
+            job['age'], job['deadline'] or PENDING_DEADLINE,
+            json.loads(job['requirements'] or '{}'), job['interpreter'], fleet)
+        if expire:
+            c.execute('''
+                UPDATE jobs SET status = 'failed', error = ?, completed_at = CURRENT_TIMESTAMP
+                WHERE id = ? AND status = 'pending'
+            ''', (reason, job['id']))
+            expired += c.rowcount
+    return expired
+
+def reclaim_expired_assignments(c) -> List[str]:
+    """
+    Return claimed jobs that were never reported to the queue.
+
+    A live node that loses a job (crash mid-run, dropped /complete) would
+    otherwise hold it forever. Past ASSIGNMENT_TTL the job can no longer be
+    running, so it goes back to pending for another node. The update
+    re-checks the status, so a report that lands first wins; a report that
+    lands after is for a job no longer assigned to that node and gets 404.
+    Each reclaimed job counts against the node that lost it.
+    """
+    c.execute('''
+        SELECT id, node_id FROM jobs
+        WHERE status IN ('assigned', 'running') AND assigned_at < datetime('now', ?)
+    ''', (f'-{ASSIGNMENT_TTL} seconds',))
+    reclaimed = []
+    for job in c.fetchall():
+        c.execute('''
+            UPDATE jobs SET status = 'pending', node_id = NULL, assigned_at = NULL
+            WHERE id = ? AND status IN ('assigned', 'running')
+        ''', (job['id'],))
+        if c.rowcount:
+            c.execute('UPDATE nodes SET reclaimed = reclaimed + 1 WHERE id = ?', (job['node_id'],))
+            reclaimed.append(job['id'])
+    return reclaimed
+
+def max_work_units(elapsed: float, requirements: Dict[str, Any]) -> float:
+    """
+    Most work one job could have been worth after elapsed seconds.
+
+    Every core the job asked for busy, the GPU busy if it asked for one, and
+    its memory held for the whole time, weighted the way sandrun counts work
+    units. Jobs that ask for nothing get sandrun's defaults, and no job runs
+    past JOB_TIMEOUT. The node's own capabilities play no part, since it
+    reports them itself.
+    """
+    rate = (max(requirements.get('cpu_cores', 0), DEFAULT_JOB_CORES) +
+            (GPU_SECOND_WORK_UNITS if requirements.get('gpu') else 0) +
+            GIB_SECOND_WORK_UNITS * max(requirements.get('memory_gb', 0), DEFAULT_JOB_MEMORY_GB))
+    return min(max(elapsed, 0), JOB_TIMEOUT) * rate
+
+def classify_report(job: Optional[Dict[str, Any]], node_id: str,
+                    result: Dict[str, Any]) -> str:
+    """
+    Classify a node's /complete report against the stored job.
+
+    'new' for the first report from the assigned node, 'duplicate' when a
+    finished job is reported again with identical content (a retried
+    request), 'conflict' when the same node reports a different result for
+    a job it already finished (equivocation), and 'unassigned' when the job
+    doesn't exist or belongs to another node.
+    """
+    if not job or job['node_id'] != node_id:
+        return 'unassigned'
+    if job['status'] in ('assigned', 'running'):
+        return 'new'
+    stored = (job['output'], job['error'], job['exit_code'], job['work_units'] or 0)
+    reported = (result['output'], result['error'], result['exit_code'], result['work_units'])
+    return 'duplicate' if stored == reported else 'conflict'
+
+def health_score(heartbeat_age: float, completed: float, failed: float, busy: int) -> Dict[str, float]:
+    """
+    Blend liveness, recent success and load into one score in [0, 1].
+
+    liveness is 1 for the first half of NODE_TIMEOUT (a heartbeat is due
+    about then), falls linearly to 0 at NODE_TIMEOUT, and gates the rest,
+    since a silent node can't run anything. success weighs the work units of
+    recent jobs against FAILURE_WORK_UNITS per failure, smoothed by one
+    failure's worth on each side (a new node starts at 0.5), so trivial jobs
+    barely move it and a node that is failing now scores low whatever its
+    lifetime record. load is 1 / (1 + jobs in flight). The components are
+    returned alongside for diagnostics.
+    """
+    grace = NODE_TIMEOUT / 2
+    liveness = min(1.0, max(0.0, 1.0 - (heartbeat_age - grace) / (NODE_TIMEOUT - grace)))
+    success = (completed + FAILURE_WORK_UNITS) / (completed + failed + 2 * FAILURE_WORK_UNITS)
+    load = 1.0 / (1 + busy)
+    return {
+        'score': round(liveness * (0.8 * success + 0.2 * load), 4),
+        'liveness': round(liveness, 4),
+        'success_rate': round(success, 4),
+        'load': round(load, 4)
+    }
+
+def node_health(c, node_id: str) -> Optional[Dict[str, float]]:
+    """
+    Current health_score for a node, or None if it isn't registered.
+
+    Only failures the node is to blame for count: jobs it timed out on, jobs
+    reclaimed from it, and equivocations. A job that ran and exited non-zero
+    is the user's code failing, so its work counts as the node's.
+    """
+    c.execute('''
+        SELECT (julianday('now') - julianday(last_heartbeat)) * 86400 AS age,
+               equivocations, reclaimed
+        FROM nodes WHERE id = ?
+    ''', (node_id,))
+    row = c.fetchone()
+    if not row:
+        return None
+    
+    c.execute('''
+        SELECT SUM(CASE WHEN exit_code = ? THEN 0 ELSE COALESCE(work_units, 0) END) AS work,
+               SUM(exit_code = ?) AS timed_out
+        FROM (SELECT exit_code, work_units FROM jobs
+              WHERE node_id = ? AND status IN ('completed', 'failed')
+              ORDER BY completed_at DESC LIMIT ?)
+    ''', (TIMEOUT_EXIT_CODE, TIMEOUT_EXIT_CODE, node_id, HEALTH_SAMPLE))
+    recent = c.fetchone()
+    
+    c.execute('''
+        SELECT COUNT(*) AS busy FROM jobs
+        WHERE node_id = ? AND status IN ('assigned', 'running')
+    ''', (node_id,))
+    busy = c.fetchone()['busy']
+    
+    # Contradicting an earlier result is worse than failing a job
+    failures = ((recent['timed_out'] or 0) + (row['reclaimed'] or 0) +
+                EQUIVOCATION_PENALTY * (row['equivocations'] or 0))
+    return health_score(row['age'] or 0, recent['work'] or 0, FAILURE_WORK_UNITS * failures, busy)
+
 def generate_job_id() -> str:
     """Generate unique job ID"""
     timestamp = datetime.now().isoformat()
@@ -96,6 +498,23 @@ def cleanup_stale_data():
         AND node_id IN (SELECT id FROM nodes WHERE active = 0)
     ''')
     
+    # Requeue jobs held too long by nodes that are still alive
+    for job_id in reclaim_expired_assignments(c):
+        print(f"Reclaimed unreported job {job_id}")
+    
+    # Give up on jobs that will never be scheduled
+    expire_unschedulable_jobs(c)
+    
+    # Drop registration nonces nobody signed in time
+    c.execute('''
+        DELETE FROM challenges WHERE created_at < datetime('now', ?)
+    ''', (f'-{CHALLENGE_TTL} seconds',))
+    
+    # And quotes nobody submitted against
+    c.execute('''
+        DELETE FROM quotes WHERE created_at < datetime('now', ?)
+    ''', (f'-{QUOTE_TTL} seconds',))
+    
     # Delete old completed jobs
     old_time = datetime.now() - timedelta(seconds=RESULT_TTL)
     c.execute('''
@@ -127,18 +546,46 @@ def submit_job():
     if not data or 'code' not in data:
         return jsonify({'error': 'Missing code'}), 400
     
-    job_id = generate_job_id()
+    interpreter = data.get('interpreter', 'python3')
+    requirements = data.get('requirements', {})
+    error = requirements_error(requirements)
+    if error:
+        return jsonify({'error': error}), 400
+    deadline = data.get('deadline', PENDING_DEADLINE)
+    if isinstance(deadline, bool) or not isinstance(deadline, int) or deadline <= 0:
+        return jsonify({'error': 'deadline must be a positive number of seconds'}), 400
     
     conn = get_db()
     c = conn.cursor()
+    
+    # A live quote already admitted this job, even if the fleet has shrunk
+    # since. Otherwise reject jobs no node in the fleet could ever serve;
+    # jobs that only lack free capacity right now stay pending
+    if 'quote_id' in data:
+        error = redeem_quote(c, data['quote_id'], interpreter, requirements)
+        if error:
+            conn.commit()
+            conn.close()
+            return jsonify({'error': error}), 400
+    else:
+        exceeds, reason = exceeds_fleet_capability(
+            requirements, interpreter, active_node_capabilities(c))
+        if exceeds:
+            conn.close()
+            return jsonify({'error': reason}), 422
+    
+    job_id = generate_job_id()
+    
     c.execute('''
-        INSERT INTO jobs (id, code, interpreter, args, status)
-        VALUES (?, ?, ?, ?, 'pending')
+        INSERT INTO jobs (id, code, interpreter, args, requirements, deadline, status)
+        VALUES (?, ?, ?, ?, ?, ?, 'pending')
     ''', (
         job_id,
         data['code'],
-        data.get('interpreter', 'python3'),
-        json.dumps(data.get('args', []))
+        interpreter,
+        json.dumps(data.get('args', [])),
+        json.dumps(requirements),
+        deadline
     ))
     conn.commit()
     conn.close()
@@ -148,6 +595,106 @@ def submit_job():
         'status': 'pending'
     })
 
+def redeem_quote(c, quote_id: Any, interpreter: str, requirements: Dict[str, Any]) -> Optional[str]:
+    """
+    Spend a quote on a submit, returning why it can't be used, or None.
+
+    The quote is deleted whether or not it matches, like a registration
+    nonce, so each one admits at most one job.
+    """
+    if not isinstance(quote_id, str):
+        return 'quote_id must be a string'
+    c.execute('''
+        SELECT interpreter, requirements FROM quotes
+        WHERE id = ? AND created_at >= datetime('now', ?)
+    ''', (quote_id, f'-{QUOTE_TTL} seconds'))
+    quote = c.fetchone()
+    c.execute('DELETE FROM quotes WHERE id = ?', (quote_id,))
+    if not quote:
+        return 'Unknown or expired quote_id; request a new /quote'
+    if quote['interpreter'] != interpreter or json.loads(quote['requirements']) != requirements:
+        return 'quote_id was issued for a different interpreter or requirements'
+    return None
+
+def average_job_seconds(c) -> Optional[float]:
+    """Mean assigned-to-finished time over finished jobs still on record"""
+    c.execute('''
+        SELECT AVG((julianday(completed_at) - julianday(assigned_at)) * 86400) AS avg_seconds
+        FROM jobs
+        WHERE status IN ('completed', 'failed')
+        AND assigned_at IS NOT NULL AND completed_at IS NOT NULL
+    ''')
+    row = c.fetchone()
+    return row['avg_seconds'] if row and row['avg_seconds'] is not None else None
+
+@app.route('/quote', methods=['POST'])
+def quote_job():
+    """Check whether a job could be scheduled and estimate when it would start"""
+    data = request.json or {}
+    interpreter = data.get('interpreter', 'python3')
+    requirements = data.get('requirements', {})
+    error = requirements_error(requirements)
+    if error:
+        return jsonify({'error': error}), 400
+    
+    conn = get_db()
+    c = conn.cursor()
+    c.execute('SELECT id, capabilities FROM nodes WHERE active = 1')
+    nodes = [(row['id'], json.loads(row['capabilities'])) for row in c.fetchall()]
+    
+    # Same admission check /submit applies
+    exceeds, reason = exceeds_fleet_capability(
+        requirements, interpreter, [caps for _, caps in nodes])
+    eligible = [node_id for node_id, caps in nodes
+                if node_satisfies(requirements, interpreter, caps)]
+    
+    quote = {
+        'schedulable': not exceeds,
+        'reason': reason or None,
+        'eligible_nodes': len(eligible),
+        'queue_ahead': 0,
+        'estimated_start_seconds': None
+    }
+    
+    if eligible:
+        # Pending jobs an eligible node could pick up first (claims are FIFO)
+        c.execute("SELECT interpreter, requirements FROM jobs WHERE status = 'pending'")
+        eligible_caps = [caps for node_id, caps in nodes if node_id in eligible]
+        ahead = sum(1 for row in c.fetchall()
+                    if any(node_satisfies(json.loads(row['requirements'] or '{}'),
+                                          row['interpreter'], caps)
+                           for caps in eligible_caps))
+        
+        placeholders = ','.join('?' * len(eligible))
+        c.execute(f'''
+            SELECT COUNT(*) AS busy FROM jobs
+            WHERE status IN ('assigned', 'running') AND node_id IN ({placeholders})
+        ''', eligible)
+        busy = c.fetchone()['busy']
+        
+        # Every eligible node works through one job at a time; with no
+        # history, assume jobs run to the timeout
+        job_seconds = average_job_seconds(c) or JOB_TIMEOUT
+        waves = (ahead + busy) // len(eligible)
+        quote['queue_ahead'] = ahead
+        quote['estimated_start_seconds'] = round(waves * job_seconds, 1)
+    elif not nodes:
+        quote['reason'] = 'No active nodes; the job would wait until one registers'
+    
+    # A schedulable quote can be booked: /submit with its quote_id admits
+    # the job without re-checking the fleet until it expires
+    if quote['schedulable']:
+        quote['quote_id'] = secrets.token_hex(16)
+        c.execute('INSERT INTO quotes (id, interpreter, requirements) VALUES (?, ?, ?)',
+                  (quote['quote_id'], interpreter, json.dumps(requirements)))
+        c.execute('SELECT datetime(created_at, ?) FROM quotes WHERE id = ?',
+                  (f'+{QUOTE_TTL} seconds', quote['quote_id']))
+        quote['expires_at'] = c.fetchone()[0]
+        conn.commit()
+    
+    conn.close()
+    return jsonify(quote)
+
 @app.route('/status/<job_id>', methods=['GET'])
 def get_status(job_id):
     """Get job status"""
@@ -165,7 +712,9 @@ def get_status(job_id):
         'status': job['status'],
         'node_id': job['node_id'],
         'created_at': job['created_at'],
-        'completed_at': job['completed_at']
+        'completed_at': job['completed_at'],
+        # Set when the broker gave up on a job that never reached a node
+        'reason': job['error'] if job['status'] == 'failed' and not job['node_id'] else None
     })
 
 @app.route('/results/<job_id>', methods=['GET'])
@@ -191,6 +740,42 @@ def get_results(job_id):
         'exit_code': job['exit_code']
     })
 
+@app.route('/leaderboard', methods=['GET'])
+def get_leaderboard():
+    """Rank nodes by ?metric= over finished jobs in the last ?window= seconds"""
+    metric = request.args.get('metric', 'success_rate')
+    try:
+        top = max(1, min(int(request.args.get('top', 10)), 100))
+        window = int(request.args.get('window', RESULT_TTL))
+    except ValueError:
+        return jsonify({'error': 'top and window must be integers'}), 400
+    if window <= 0:
+        return jsonify({'error': 'window must be positive'}), 400
+    
+    conn = get_db()
+    c = conn.cursor()
+    c.execute('''
+        SELECT node_id,
+               SUM(status = 'completed') AS completed,
+               SUM(status = 'failed') AS failed,
+               SUM(CASE WHEN status = 'completed' THEN COALESCE(work_units, 0) ELSE 0 END) AS work_units,
+               AVG((julianday(completed_at) - julianday(assigned_at)) * 86400) AS avg_latency
+        FROM jobs
+        WHERE status IN ('completed', 'failed')
+        AND node_id IS NOT NULL
+        AND completed_at >= datetime('now', ?)
+        GROUP BY node_id
+    ''', (f'-{window} seconds',))
+    history = [dict(row) for row in c.fetchall()]
+    conn.close()
+    
+    try:
+        ranked = leaderboard(history, metric, top)
+    except ValueError as e:
+        return jsonify({'error': str(e)}), 400
+    
+    return jsonify({'metric': metric, 'window': window, 'nodes': ranked})
+
 @app.route('/nodes', methods=['GET'])
 def list_nodes():
     """List registered nodes"""
@@ -198,7 +783,8 @@ def list_nodes():
     c = conn.cursor()
     c.execute('''
         SELECT id, endpoint, capabilities, last_heartbeat, 
-               jobs_completed, jobs_failed, active
+               jobs_completed, jobs_failed, work_units, equivocations,
+               reclaimed, active, identity_key
         FROM nodes 
         WHERE active = 1
         ORDER BY last_heartbeat DESC
@@ -206,13 +792,35 @@ def list_nodes():
     nodes = [dict(row) for row in c.fetchall()]
     conn.close()
     
+    conn = get_db()
+    c = conn.cursor()
     for node in nodes:
         node['capabilities'] = json.loads(node['capabilities'])
+        node['health'] = node_health(c, node['id'])
+    conn.close()
     
     return jsonify({'nodes': nodes})
 
 # Node API (internal)
 
+@app.route('/challenge', methods=['POST'])
+def issue_challenge():
+    """Issue a one-time nonce for a keyed node to sign when registering"""
+    data = request.json
+    
+    if not data or not isinstance(data.get('identity_key'), str):
+        return jsonify({'error': 'Missing identity_key'}), 400
+    
+    nonce = secrets.token_hex(32)
+    conn = get_db()
+    c = conn.cursor()
+    c.execute('INSERT INTO challenges (nonce, identity_key) VALUES (?, ?)',
+              (nonce, data['identity_key']))
+    conn.commit()
+    conn.close()
+    
+    return jsonify({'nonce': nonce, 'expires_in': CHALLENGE_TTL})
+
 @app.route('/register', methods=['POST'])
 def register_node():
     """Register new node"""
@@ -221,22 +829,78 @@ def register_node():
     if not data or 'endpoint' not in data:
         return jsonify({'error': 'Missing endpoint'}), 400
     
-    node_id = hashlib.sha256(data['endpoint'].encode()).hexdigest()[:16]
+    # Scheduling compares these against job requirements, so they must be
+    # numbers like the requirements are
+    error = capabilities_error(data.get('capabilities', {}))
+    if error:
+        return jsonify({'error': error}), 400
     
     conn = get_db()
     c = conn.cursor()
+    
+    # Identity keys are public, so a keyed node must sign a fresh nonce
+    # with its worker key. The nonce is spent whether or not it verifies.
+    identity_key = data.get('identity_key')
+    if identity_key is not None:
+        c.execute('''
+            DELETE FROM challenges
+            WHERE nonce = ? AND identity_key = ? AND created_at >= datetime('now', ?)
+        ''', (data.get('nonce'), identity_key, f'-{CHALLENGE_TTL} seconds'))
+        issued = c.rowcount == 1
+        conn.commit()
+        if not issued or not identity_signature_valid(
+                identity_key, registration_message(data['nonce'], data['endpoint']),
+                data.get('signature')):
+            conn.close()
+            return jsonify({'error': 'identity_key not proven: sign a fresh /challenge nonce'}), 401
+    
+    # Node ID derives from the persistent identity, so a node reappearing
+    # at a new endpoint keeps its ID and job history
+    node_id = derive_node_id(data)
+    c.execute('SELECT secret_hash FROM nodes WHERE id = ?', (node_id,))
+    existing = c.fetchone()
+    if not existing and adopt_legacy_node(c, data, node_id):
+        c.execute('SELECT secret_hash FROM nodes WHERE id = ?', (node_id,))
+        existing = c.fetchone()
+    
+    # A keyed node has just proven who it is and gets a fresh secret. An
+    # endpoint-derived ID is only taken over by the node holding the secret
+    # issued at its first registration; a row from before secrets has none,
+    # so its first registration since is issued one.
+    if identity_key is None and existing and existing['secret_hash']:
+        if not node_secret_matches(data.get('node_secret'), existing['secret_hash']):
+            conn.close()
+            return jsonify({'error': f'Duplicate node ID: {node_id}'}), 409
+        node_secret = None
+    else:
+        node_secret = secrets.token_hex(32)
+    
     c.execute('''
-        INSERT OR REPLACE INTO nodes (id, endpoint, capabilities, last_heartbeat, active)
-        VALUES (?, ?, ?, CURRENT_TIMESTAMP, 1)
+        INSERT INTO nodes (id, endpoint, capabilities, last_heartbeat, active, identity_key)
+        VALUES (?, ?, ?, CURRENT_TIMESTAMP, 1, ?)
+        ON CONFLICT(id) DO UPDATE SET
+            endpoint = excluded.endpoint,
+            capabilities = excluded.capabilities,
+            last_heartbeat = CURRENT_TIMESTAMP,
+            active = 1,
+            identity_key = excluded.identity_key
     ''', (
         node_id,
         data['endpoint'],
-        json.dumps(data.get('capabilities', {}))
+        json.dumps(data.get('capabilities', {})),
+        identity_key
     ))
+    if node_secret:
+        c.execute('UPDATE nodes SET secret_hash = ? WHERE id = ?',
+                  (hash_node_secret(node_secret), node_id))
     conn.commit()
     conn.close()
     
-    return jsonify({'node_id': node_id})
+    response = {'node_id': node_id}
+    if node_secret:
+        # Only returned here; the node sends it on every node-scoped call
+        response['node_secret'] = node_secret
+    return jsonify(response)
 
 @app.route('/heartbeat', methods=['POST'])
 def heartbeat():
@@ -248,6 +912,10 @@ def heartbeat():
     
     conn = get_db()
     c = conn.cursor()
+    if not authenticate_node(c, data):
+        conn.close()
+        return jsonify({'error': 'Unknown node or bad node_secret'}), 401
+    
     c.execute('''
         UPDATE nodes 
         SET last_heartbeat = CURRENT_TIMESTAMP, active = 1
@@ -268,16 +936,31 @@ def claim_job():
     
     conn = get_db()
     c = conn.cursor()
+    if not authenticate_node(c, data):
+        conn.close()
+        return jsonify({'error': 'Unknown node or bad node_secret'}), 401
+    
+    c.execute('SELECT capabilities FROM nodes WHERE id = ?', (data['node_id'],))
+    node = c.fetchone()
+    capabilities = json.loads(node['capabilities']) if node else {}
     
-    # Find next pending job
+    # Alive but failing nodes sit out until their recent failures age out
+    health = node_health(c, data['node_id'])
+    if health and health['score'] < HEALTH_THRESHOLD:
+        conn.close()
+        return jsonify({'job': None, 'health': health,
+                        'reason': f"Health score {health['score']} below {HEALTH_THRESHOLD}"})
+    
+    # Find next pending job this node can run; code is only loaded for it
     c.execute('''
-        SELECT id, code, interpreter, args 
+        SELECT id, interpreter, requirements 
         FROM jobs 
         WHERE status = 'pending'
         ORDER BY created_at ASC
-        LIMIT 1
     ''')
-    job = c.fetchone()
+    job = next((row for row in c.fetchall()
+                if node_satisfies(json.loads(row['requirements'] or '{}'),
+                                  row['interpreter'], capabilities)), None)
     
     if not job:
         conn.close()
@@ -297,15 +980,17 @@ def claim_job():
         conn.close()
         return jsonify({'job': None})
     
+    c.execute('SELECT code, args FROM jobs WHERE id = ?', (job['id'],))
+    payload = c.fetchone()
     conn.commit()
     conn.close()
     
     return jsonify({
         'job': {
             'id': job['id'],
-            'code': job['code'],
+            'code': payload['code'],
             'interpreter': job['interpreter'],
-            'args': json.loads(job['args'])
+            'args': json.loads(payload['args'])
         }
     })
 
@@ -318,8 +1003,56 @@ def complete_job():
     if not data or not all(k in data for k in required):
         return jsonify({'error': 'Missing required fields'}), 400
     
+    # Compute performed, from sandrun's execution metadata; credited
+    # instead of a flat per-job count so trivial jobs can't farm reputation
+    work_units = data.get('work_units', 0)
+    if isinstance(work_units, bool) or not isinstance(work_units, (int, float)) or work_units < 0:
+        return jsonify({'error': 'work_units must be a non-negative number'}), 400
+    
     conn = get_db()
     c = conn.cursor()
+    if not authenticate_node(c, data):
+        conn.close()
+        return jsonify({'error': 'Unknown node or bad node_secret'}), 401
+    
+    # Reports are retried over flaky networks: a repeat of the stored result
+    # is a no-op, a different one for the same job is equivocation
+    c.execute('SELECT CURRENT_TIMESTAMP')
+    now = c.fetchone()[0]
+    c.execute('''
+        SELECT node_id, status, output, error, exit_code, work_units, equivocated,
+               (julianday(COALESCE(completed_at, ?)) - julianday(assigned_at)) * 86400 AS elapsed,
+               requirements
+        FROM jobs WHERE id = ?
+    ''', (now, data['job_id']))
+    row = c.fetchone()
+    job = dict(row) if row else None
+    
+    # Credit no more than the job could be worth between claim and report;
+    # finished jobs reuse their completion time, so a retry is clamped the
+    # same way as the original
+    if job:
+        work_units = min(work_units, max_work_units(
+            job['elapsed'] or 0, json.loads(job['requirements'] or '{}')))
+    kind = classify_report(job, data['node_id'], dict(data, work_units=work_units))
+    if kind == 'unassigned':
+        conn.close()
+        return jsonify({'error': 'Job is not assigned to this node'}), 404
+    if kind == 'duplicate':
+        conn.close()
+        return jsonify({'status': 'ok', 'duplicate': True})
+    if kind == 'conflict':
+        # Count one equivocation per job, however many more conflicting
+        # reports the node sends for it
+        if not job['equivocated']:
+            c.execute('''
+                UPDATE nodes SET equivocations = equivocations + 1 WHERE id = ?
+            ''', (data['node_id'],))
+            c.execute('UPDATE jobs SET equivocated = 1 WHERE id = ?', (data['job_id'],))
+            conn.commit()
+        conn.close()
+        return jsonify({'error': 'Conflicting result for a job already reported',
+                        'equivocation': True}), 409
     
     # Update job with results
     status = 'completed' if data['exit_code'] == 0 else 'failed'
@@ -329,13 +1062,16 @@ def complete_job():
             output = ?, 
             error = ?, 
             exit_code = ?,
-            completed_at = CURRENT_TIMESTAMP
+            work_units = ?,
+            completed_at = ?
         WHERE id = ? AND node_id = ?
     ''', (
         status,
         data['output'],
         data['error'],
         data['exit_code'],
+        work_units,
+        now,
         data['job_id'],
         data['node_id']
     ))
@@ -344,9 +1080,10 @@ def complete_job():
     if status == 'completed':
         c.execute('''
             UPDATE nodes 
-            SET jobs_completed = jobs_completed + 1
+            SET jobs_completed = jobs_completed + 1,
+                work_units = work_units + ?
             WHERE id = ?
-        ''', (data['node_id'],))
+        ''', (work_units, data['node_id']))
     else:
         c.execute('''
             UPDATE nodes 
@@ -0,0 +1,367 @@
+"""
+Unit tests for the broker's decision helpers.
+
+Run with:
+    pip install -r requirements-test.txt
+    pytest test_broker.py
+"""
+
+import pytest
+
+import broker
+
+
+# ============================================================================
+# Leaderboard
+# ============================================================================
+
+def history_entry(node_id, completed, failed, work_units=0.0, avg_latency=None):
+    return {'node_id': node_id, 'completed': completed, 'failed': failed,
+            'work_units': work_units, 'avg_latency': avg_latency}
+
+
+def test_leaderboard_ranks_by_success_rate_with_node_id_tiebreak():
+    history = [
+        history_entry('c', 9, 1),
+        history_entry('b', 1, 1),
+        history_entry('a', 9, 1),
+    ]
+    ranked = broker.leaderboard(history, 'success_rate', 10)
+    assert [(n['node_id'], n['rank']) for n in ranked] == [('a', 1), ('c', 2), ('b', 3)]
+    assert ranked[0]['success_rate'] == pytest.approx(0.9)
+
+
+def test_leaderboard_lowest_latency_first_and_skips_unknown():
+    history = [
+        history_entry('slow', 5, 0, avg_latency=30.0),
+        history_entry('fast', 5, 0, avg_latency=2.0),
+        history_entry('unknown', 5, 0),
+    ]
+    ranked = broker.leaderboard(history, 'avg_latency', 10)
+    assert [n['node_id'] for n in ranked] == ['fast', 'slow']
+
+
+def test_leaderboard_skips_idle_nodes_and_truncates():
+    history = [
+        history_entry('idle', 0, 0, work_units=100.0),
+        history_entry('big', 2, 0, work_units=50.0),
+        history_entry('small', 2, 0, work_units=5.0),
+    ]
+    ranked = broker.leaderboard(history, 'work_units', 1)
+    assert [n['node_id'] for n in ranked] == ['big']
+
+
+def test_leaderboard_rejects_unknown_metric():
+    with pytest.raises(ValueError, match="Unknown metric"):
+        broker.leaderboard([], 'jobs', 10)
+
+
+# ============================================================================
+# Health score
+# ============================================================================
+
+def test_health_score_new_node_starts_neutral():
+    health = broker.health_score(0, 0, 0, 0)
+    assert health == {'score': 0.6, 'liveness': 1.0, 'success_rate': 0.5, 'load': 1.0}
+
+
+def test_health_score_liveness_decays_after_grace():
+    midway = broker.NODE_TIMEOUT * 0.75
+    assert broker.health_score(broker.NODE_TIMEOUT / 2, 5, 0, 0)['liveness'] == 1.0
+    assert broker.health_score(midway, 5, 0, 0)['liveness'] == pytest.approx(0.5)
+    silent = broker.health_score(broker.NODE_TIMEOUT + 10, 100, 0, 0)
+    assert silent['liveness'] == 0.0 and silent['score'] == 0.0
+
+
+def test_health_score_recent_failures_fall_below_threshold():
+    failing = broker.health_score(0, 0, 18 * broker.FAILURE_WORK_UNITS, 0)
+    assert failing['success_rate'] == pytest.approx(0.05)
+    assert failing['score'] < broker.HEALTH_THRESHOLD
+
+
+def test_health_score_weighs_work_not_job_count():
+    trivial = broker.health_score(0, 20 * 0.01, broker.FAILURE_WORK_UNITS, 0)
+    heavy = broker.health_score(0, 10 * broker.FAILURE_WORK_UNITS, broker.FAILURE_WORK_UNITS, 0)
+    assert trivial['success_rate'] == pytest.approx(1 / 3, abs=1e-3)
+    assert heavy['success_rate'] == pytest.approx(11 / 13, abs=1e-3)
+
+
+def test_health_score_load_prefers_idle_nodes():
+    idle = broker.health_score(0, 10, 0, 0)
+    busy = broker.health_score(0, 10, 0, 3)
+    assert busy['load'] == 0.25
+    assert busy['score'] < idle['score']
+
+
+# ============================================================================
+# Pending expiry
+# ============================================================================
+
+SMALL_NODE = {'cpu_cores': 2, 'memory_gb': 4, 'gpu': False, 'interpreters': ['python3']}
+
+
+def test_capabilities_error_accepts_well_formed_capabilities():
+    assert broker.capabilities_error(SMALL_NODE) is None
+    assert broker.capabilities_error({}) is None
+    assert broker.capabilities_error([SMALL_NODE]) == 'capabilities must be an object'
+
+
+@pytest.mark.parametrize('capabilities, field', [
+    ({'cpu_cores': '8'}, 'cpu_cores'),
+    ({'memory_gb': True}, 'memory_gb'),
+    ({'memory_gb': -1}, 'memory_gb'),
+    ({'gpu': 'yes'}, 'gpu'),
+    ({'interpreters': 'python3'}, 'interpreters'),
+    ({'interpreters': [3]}, 'interpreters'),
+])
+def test_capabilities_error_names_the_bad_field(capabilities, field):
+    assert field in broker.capabilities_error(capabilities)
+
+
+def test_should_expire_pending_waits_before_threshold():
+    # Unschedulable, but not yet a quarter of the way to its deadline
+    assert broker.should_expire_pending(100, 1000, {'gpu': True}, 'python3', [SMALL_NODE],
+                                        threshold=0.25) == (False, '')
+
+
+def test_should_expire_pending_expires_unschedulable_early():
+    expire, reason = broker.should_expire_pending(300, 1000, {'gpu': True}, 'python3',
+                                                  [SMALL_NODE], threshold=0.25)
+    assert expire
+    assert reason == "Unschedulable: Job requires a GPU; no active node has one"
+
+    expire, reason = broker.should_expire_pending(300, 1000, {}, 'python3', [], threshold=0.25)
+    assert expire and reason == "Unschedulable: no active nodes"
+
+
+def test_should_expire_pending_keeps_schedulable_jobs_until_deadline():
+    assert broker.should_expire_pending(900, 1000, {'cpu_cores': 2}, 'python3',
+                                        [SMALL_NODE]) == (False, '')
+    expire, reason = broker.should_expire_pending(1000, 1000, {'cpu_cores': 2}, 'python3',
+                                                  [SMALL_NODE])
+    assert expire
+    assert reason == "Unschedulable: not started within its 1000s deadline"
+
+
+# ============================================================================
+# Result reports
+# ============================================================================
+
+REPORT = {'output': 'ok', 'error': '', 'exit_code': 0, 'work_units': 3.0}
+
+
+def stored_job(status, node_id='n1', **result):
+    return dict({'node_id': node_id, 'status': status}, **dict(REPORT, **result))
+
+
+def test_classify_report_first_report_is_new():
+    assert broker.classify_report(stored_job('assigned', output=None), 'n1', REPORT) == 'new'
+    assert broker.classify_report(stored_job('running', output=None), 'n1', REPORT) == 'new'
+
+
+def test_classify_report_retry_is_duplicate():
+    assert broker.classify_report(stored_job('completed'), 'n1', REPORT) == 'duplicate'
+    # A job finished with no work units reads back as NULL
+    assert broker.classify_report(stored_job('failed', work_units=None), 'n1',
+                                  dict(REPORT, work_units=0)) == 'duplicate'
+
+
+@pytest.mark.parametrize("change", [
+    {'output': 'other'}, {'error': 'boom'}, {'exit_code': 1}, {'work_units': 4.0},
+])
+def test_classify_report_different_result_is_conflict(change):
+    assert broker.classify_report(stored_job('completed'), 'n1', dict(REPORT, **change)) == 'conflict'
+
+
+def test_classify_report_unknown_or_other_nodes_job_is_unassigned():
+    assert broker.classify_report(None, 'n1', REPORT) == 'unassigned'
+    assert broker.classify_report(stored_job('assigned', node_id='n2'), 'n1', REPORT) == 'unassigned'
+    assert broker.classify_report(stored_job('pending', node_id=None), 'n1', REPORT) == 'unassigned'
+
+
+def test_max_work_units_bounded_by_requirements_and_timeout():
+    default = broker.DEFAULT_JOB_CORES + broker.GIB_SECOND_WORK_UNITS * broker.DEFAULT_JOB_MEMORY_GB
+    assert broker.max_work_units(10, {}) == pytest.approx(10 * default)
+    gpu = {'cpu_cores': 4, 'memory_gb': 8, 'gpu': True}
+    assert broker.max_work_units(10, gpu) == pytest.approx(10 * (4 + broker.GPU_SECOND_WORK_UNITS + 0.8))
+    assert broker.max_work_units(broker.JOB_TIMEOUT * 10, {}) == broker.FAILURE_WORK_UNITS
+    assert broker.max_work_units(-5, gpu) == 0
+
+
+# ============================================================================
+# Assignment reclamation
+# ============================================================================
+
+@pytest.fixture
+def db(tmp_path):
+    original = broker.DB_PATH
+    broker.DB_PATH = str(tmp_path / 'broker.db')
+    broker.init_db()
+    conn = broker.get_db()
+    yield conn
+    conn.close()
+    broker.DB_PATH = original
+
+
+def add_claimed_job(conn, job_id, age, status='assigned', node_id='n1'):
+    conn.execute('''
+        INSERT INTO jobs (id, code, status, node_id, assigned_at)
+        VALUES (?, 'print(1)', ?, ?, datetime('now', ?))
+    ''', (job_id, status, node_id, f'-{age} seconds'))
+    conn.commit()
+
+
+def job_row(conn, job_id):
+    return dict(conn.execute('SELECT * FROM jobs WHERE id = ?', (job_id,)).fetchone())
+
+
+def test_reclaim_returns_only_expired_assignments(db):
+    add_claimed_job(db, 'stale', broker.ASSIGNMENT_TTL + 10)
+    add_claimed_job(db, 'running', broker.ASSIGNMENT_TTL + 10, status='running')
+    add_claimed_job(db, 'fresh', 10)
+    add_claimed_job(db, 'done', broker.ASSIGNMENT_TTL + 10, status='completed')
+
+    assert sorted(broker.reclaim_expired_assignments(db.cursor())) == ['running', 'stale']
+    stale = job_row(db, 'stale')
+    assert (stale['status'], stale['node_id'], stale['assigned_at']) == ('pending', None, None)
+    assert job_row(db, 'fresh')['status'] == 'assigned'
+    assert job_row(db, 'done')['status'] == 'completed'
+
+
+def add_node(conn, node_id='n1'):
+    conn.execute('''
+        INSERT INTO nodes (id, endpoint, capabilities, last_heartbeat)
+        VALUES (?, 'http://node', '{}', CURRENT_TIMESTAMP)
+    ''', (node_id,))
+    conn.commit()
+
+
+def add_finished_job(conn, job_id, exit_code, node_id='n1', work_units=0):
+    conn.execute('''
+        INSERT INTO jobs (id, code, status, node_id, exit_code, work_units, completed_at)
+        VALUES (?, 'print(1)', ?, ?, ?, ?, CURRENT_TIMESTAMP)
+    ''', (job_id, 'completed' if exit_code == 0 else 'failed', node_id, exit_code, work_units))
+    conn.commit()
+
+
+def test_reclaim_counts_against_the_node(db):
+    add_node(db)
+    add_claimed_job(db, 'job', broker.ASSIGNMENT_TTL + 10)
+    broker.reclaim_expired_assignments(db.cursor())
+    node = db.execute("SELECT reclaimed FROM nodes WHERE id = 'n1'").fetchone()
+    assert node['reclaimed'] == 1
+    assert broker.node_health(db.cursor(), 'n1')['success_rate'] == round(1 / 3, 4)
+
+
+def test_node_health_ignores_user_code_failures(db):
+    add_node(db)
+    add_finished_job(db, 'ok', 0, work_units=30)
+    add_finished_job(db, 'user-error', 1, work_units=10)
+    health = broker.node_health(db.cursor(), 'n1')
+    assert health['success_rate'] == broker.health_score(0, 40, 0, 0)['success_rate']
+
+
+def test_node_health_counts_timeouts(db):
+    add_node(db)
+    add_finished_job(db, 'ok', 0, work_units=30)
+    add_finished_job(db, 'hung', broker.TIMEOUT_EXIT_CODE, work_units=30)
+    health = broker.node_health(db.cursor(), 'n1')
+    expected = broker.health_score(0, 30, broker.FAILURE_WORK_UNITS, 0)
+    assert health['success_rate'] == expected['success_rate']
+
+
+def test_node_id_differs_for_key_and_equal_endpoint():
+    key = 'c2FtZS1zdHJpbmc='
+    assert broker.derive_node_id({'identity_key': key, 'endpoint': 'http://a'}) != \
+        broker.derive_node_id({'endpoint': key})
+
+
+def legacy_id(value):
+    return broker.hashlib.sha256(value.encode()).hexdigest()[:16]
+
+
+def test_adopt_legacy_node_moves_row_and_jobs(db):
+    data = {'endpoint': 'http://old'}
+    add_node(db, legacy_id('http://old'))
+    add_finished_job(db, 'done', 0, node_id=legacy_id('http://old'))
+    node_id = broker.derive_node_id(data)
+
+    assert broker.adopt_legacy_node(db.cursor(), data, node_id)
+    assert db.execute('SELECT id FROM nodes').fetchall()[0]['id'] == node_id
+    assert job_row(db, 'done')['node_id'] == node_id
+
+
+def test_adopt_legacy_node_keeps_identity_kinds_apart(db):
+    key = 'c2FtZS1zdHJpbmc='
+    add_node(db, legacy_id(key))
+    keyed = {'identity_key': key, 'endpoint': 'http://a'}
+    assert not broker.adopt_legacy_node(db.cursor(), keyed, broker.derive_node_id(keyed))
+
+    db.execute('UPDATE nodes SET identity_key = ?', (key,))
+    anonymous = {'endpoint': key}
+    assert not broker.adopt_legacy_node(db.cursor(), anonymous, broker.derive_node_id(anonymous))
+    assert broker.adopt_legacy_node(db.cursor(), keyed, broker.derive_node_id(keyed))
+
+
+def test_reclaim_then_late_report_is_unassigned(db):
+    add_claimed_job(db, 'job', broker.ASSIGNMENT_TTL + 10)
+    broker.reclaim_expired_assignments(db.cursor())
+    assert broker.classify_report(job_row(db, 'job'), 'n1', REPORT) == 'unassigned'
+
+
+class ReportBeforeUpdate:
+    """Cursor that lets a node's report commit between reclaim's select and update"""
+
+    def __init__(self, conn, report):
+        self.cursor = conn.cursor()
+        self.report = report
+
+    def execute(self, sql, params=()):
+        if sql.lstrip().startswith('UPDATE') and self.report:
+            self.report()
+            self.report = None
+        return self.cursor.execute(sql, params)
+
+    def __getattr__(self, name):
+        return getattr(self.cursor, name)
+
+
+def test_reclaim_loses_to_report_that_lands_first(db):
+    add_claimed_job(db, 'job', broker.ASSIGNMENT_TTL + 10)
+
+    def report():
+        other = broker.get_db()
+        other.execute("UPDATE jobs SET status = 'completed', output = 'ok' WHERE id = 'job'")
+        other.commit()
+        other.close()
+
+    assert broker.reclaim_expired_assignments(ReportBeforeUpdate(db, report)) == []
+    job = job_row(db, 'job')
+    assert (job['status'], job['node_id'], job['output']) == ('completed', 'n1', 'ok')
+
+
+# ============================================================================
+# Quotes
+# ============================================================================
+
+def add_quote(conn, quote_id, requirements, age=0, interpreter='python3'):
+    conn.execute('''
+        INSERT INTO quotes (id, interpreter, requirements, created_at)
+        VALUES (?, ?, ?, datetime('now', ?))
+    ''', (quote_id, interpreter, broker.json.dumps(requirements), f'-{age} seconds'))
+
+
+def test_redeem_quote_admits_once(db):
+    add_quote(db, 'q1', {'cpu_cores': 2})
+    assert broker.redeem_quote(db.cursor(), 'q1', 'python3', {'cpu_cores': 2}) is None
+    assert 'expired' in broker.redeem_quote(db.cursor(), 'q1', 'python3', {'cpu_cores': 2})
+
+
+def test_redeem_quote_rejects_expired_and_mismatched(db):
+    add_quote(db, 'old', {}, age=broker.QUOTE_TTL + 10)
+    add_quote(db, 'q1', {'cpu_cores': 2})
+    add_quote(db, 'q2', {'cpu_cores': 2})
+    assert 'expired' in broker.redeem_quote(db.cursor(), 'old', 'python3', {})
+    assert 'different' in broker.redeem_quote(db.cursor(), 'q1', 'python3', {'cpu_cores': 8})
+    assert 'different' in broker.redeem_quote(db.cursor(), 'q2', 'node', {'cpu_cores': 2})
+    assert broker.redeem_quote(db.cursor(), 5, 'python3', {}) == 'quote_id must be a string'
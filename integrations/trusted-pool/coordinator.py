@@ -10,10 +10,17 @@ Usage:
 """
 
 import asyncio
+import collections
+import copy
+import functools
+import hashlib
 import json
+import random
+import re
+import secrets
 import time
-from typing import Dict, List, Optional
-from dataclasses import dataclass, asdict
+from typing import Any, Deque, Dict, List, Optional, Tuple
+from dataclasses import dataclass, asdict, field
 from pathlib import Path
 import argparse
 import aiohttp
@@ -23,6 +30,384 @@ import logging
 logging.basicConfig(level=logging.INFO)
 logger = logging.getLogger(__name__)
 
+# Length of a scheduling epoch; tie-break order among equally loaded
+# workers is fixed within an epoch and rotates between epochs
+EPOCH_SECONDS = 3600
+
+# Number of recent events kept for replay
+EVENT_BUFFER_SIZE = 10000
+
+# Seconds between keepalive comments on an idle /events/stream connection
+EVENT_STREAM_KEEPALIVE = 15
+
+# Worker state older than this (seconds, three missed health checks) is
+# low-confidence; such workers are only used when no fresh one is free
+CAPABILITY_MAX_AGE = 90
+
+# Consecutive failures before a worker's circuit breaker opens, and how
+# long (seconds) it stays open before letting a probe request through
+BREAKER_THRESHOLD = 5
+BREAKER_COOLDOWN = 60
+
+# Worst-case job runtime assumed when the manifest sets no timeout
+# (matches sandrun's DEFAULT_TIMEOUT_SECONDS)
+DEFAULT_JOB_TIMEOUT = 300
+
+# An accepted job that misses its signed deadline costs the worker this many
+# declines' worth of ranking: turning work away is fine, abandoning it is not
+ABANDON_PENALTY = 5
+
+# Only declines within this many seconds count toward a worker's ranking,
+# so one busy spell doesn't demote it for good
+DECLINE_WINDOW = 600
+
+# /submit statuses that mean the worker turned the job away: over capacity,
+# or not accepting work from this pool. Other 4xx mean the job itself is
+# bad and fail it; 429 and other 5xx are transient and only requeue
+DECLINE_STATUSES = {401, 403, 503}
+
+# Queue depth at which the pool counts as bursting and starts routing jobs
+# to standby workers, which otherwise sit idle in reserve
+STANDBY_QUEUE_DEPTH = 10
+
+# Seconds a finished job's uploaded files are kept for resubmission, and
+# the longest they are kept for any job: status is only learned when a
+# client polls, so a job nobody asks about may never be seen to finish
+RESUBMIT_WINDOW = 3600
+FILES_MAX_AGE = 86400
+
+
+def current_epoch(now: Optional[float] = None) -> int:
+    """Return the scheduling epoch for a timestamp (defaults to now)"""
+    if now is None:
+        now = time.time()
+    return int(now // EPOCH_SECONDS)
+
+
+def shuffle_candidates(workers: List["Worker"], job_id: str, epoch: int) -> List["Worker"]:
+    """
+    Deterministically shuffle workers for a job within an epoch.
+
+    Each worker is ordered by sha256(job_id|epoch|worker_id), so anyone
+    holding the same inputs can reproduce the order, while the preferred
+    worker for a job rotates from one epoch to the next.
+    """
+    def shuffle_key(worker: "Worker") -> str:
+        seed = f"{job_id}|{epoch}|{worker.worker_id}".encode()
+        return hashlib.sha256(seed).hexdigest()
+
+    return sorted(workers, key=shuffle_key)
+
+
+class RandSource:
+    """
+    Source of randomness for the coordinator.
+
+    Everything non-deterministic the coordinator does (job IDs, any
+    randomized selection) draws from one injected source, so production
+    uses the OS CSPRNG and tests can substitute a seeded one.
+    """
+
+    def int63(self) -> int:
+        """Return a non-negative 63-bit integer"""
+        raise NotImplementedError
+
+    def bytes(self, n: int) -> bytes:
+        """Return n random bytes"""
+        raise NotImplementedError
+
+
+class SystemRandSource(RandSource):
+    """Cryptographically secure source backed by the secrets module"""
+
+    def int63(self) -> int:
+        return secrets.randbits(63)
+
+    def bytes(self, n: int) -> bytes:
+        return secrets.token_bytes(n)
+
+
+class SeededRandSource(RandSource):
+    """Deterministic source for tests and reproducible runs; not secure"""
+
+    def __init__(self, seed: int):
+        self._rng = random.Random(seed)
+
+    def int63(self) -> int:
+        return self._rng.getrandbits(63)
+
+    def bytes(self, n: int) -> bytes:
+        return self._rng.getrandbits(8 * n).to_bytes(n, "big") if n > 0 else b""
+
+
+def _sign(value: float) -> int:
+    return (value > 0) - (value < 0)
+
+
+def compare_health(a: "Worker", b: "Worker", manifest: Optional[Dict] = None) -> int:
+    """Healthy workers rank ahead of unhealthy ones"""
+    return _sign(int(b.is_healthy) - int(a.is_healthy))
+
+
+def compare_load(a: "Worker", b: "Worker", manifest: Optional[Dict] = None) -> int:
+    """Workers with fewer active jobs rank first"""
+    return _sign(a.active_jobs - b.active_jobs)
+
+
+def compare_spare_capacity(a: "Worker", b: "Worker", manifest: Optional[Dict] = None) -> int:
+    """Workers with more free job slots rank first"""
+    spare_a = a.max_concurrent_jobs - a.active_jobs
+    spare_b = b.max_concurrent_jobs - b.active_jobs
+    return _sign(spare_b - spare_a)
+
+
+def compare_preference(a: "Worker", b: "Worker", manifest: Optional[Dict] = None) -> int:
+    """Workers matching more of the job's preferred selectors rank first"""
+    constraints = (manifest or {}).get("node_constraints") or {}
+    return _sign(preference_score(b, constraints) - preference_score(a, constraints))
+
+
+def compare_reliability(a: "Worker", b: "Worker", manifest: Optional[Dict] = None) -> int:
+    """Workers with fewer recent declines and abandoned jobs rank first"""
+    return _sign(a.penalty() - b.penalty())
+
+
+# Default ranking, most significant first
+RANKING_COMPONENTS = [compare_health, compare_preference, compare_load,
+                      compare_reliability, compare_spare_capacity]
+
+
+def compare_workers(a: "Worker", b: "Worker", manifest: Optional[Dict] = None) -> int:
+    """
+    Compare two workers by the coordinator's default ranking.
+
+    Returns -1 if a ranks ahead of b, 1 if b ranks ahead, 0 if they are
+    equivalent (the epoch shuffle decides between those). Usable with
+    sorted(workers, key=functools.cmp_to_key(compare_workers)).
+    """
+    for component in RANKING_COMPONENTS:
+        result = component(a, b, manifest)
+        if result != 0:
+            return result
+    return 0
+
+
+_SET_TERM = re.compile(r"^([\w.\-/]+)\s+(in|notin)\s*\(([^()]*)\)$")
+_EQUALITY_TERM = re.compile(r"^([\w.\-/]+)\s*(==|=|!=)\s*([\w.\-/]*)$")
+
+
+def _split_selector(selector: str) -> List[str]:
+    """Split a selector on commas that are not inside parentheses"""
+    terms, depth, current = [], 0, ""
+    for ch in selector:
+        if ch == "(":
+            depth += 1
+        elif ch == ")":
+            depth -= 1
+            if depth < 0:
+                raise ValueError(f"Unbalanced ')' in selector: {selector!r}")
+        if ch == "," and depth == 0:
+            terms.append(current)
+            current = ""
+        else:
+            current += ch
+    if depth != 0:
+        raise ValueError(f"Unbalanced '(' in selector: {selector!r}")
+    terms.append(current)
+    return [t.strip() for t in terms if t.strip()]
+
+
+def match_labels(labels: Dict[str, str], selector: str) -> bool:
+    """
+    Check job labels against a label selector.
+
+    Supported terms, joined by commas (all must match):
+        key=value, key==value   label equals value
+        key!=value              label missing or different
+        key in (a,b)            label is one of the values
+        key notin (a,b)         label missing or none of the values
+
+    An empty selector matches everything. Raises ValueError on a
+    malformed selector.
+    """
+    for term in _split_selector(selector):
+        set_match = _SET_TERM.match(term)
+        if set_match:
+            key, op, values = set_match.groups()
+            allowed = {v.strip() for v in values.split(",") if v.strip()}
+            present = key in labels and labels[key] in allowed
+            if (op == "in") != present:
+                return False
+            continue
+
+        eq_match = _EQUALITY_TERM.match(term)
+        if eq_match:
+            key, op, value = eq_match.groups()
+            equal = labels.get(key) == value
+            if (op == "!=") == equal:
+                return False
+            continue
+
+        raise ValueError(f"Invalid selector term: {term!r}")
+
+    return True
+
+
+class DependencyUnavailable(Exception):
+    """Raised instead of calling a worker whose circuit breaker is open"""
+
+    def __init__(self, worker_id: str, retry_after: float):
+        super().__init__(f"Worker {worker_id[:16]}... unavailable, retry later")
+        self.retry_after = retry_after
+
+
+class Breaker:
+    """
+    Consecutive-failure circuit breaker.
+
+    Closed: calls go through. After `threshold` consecutive failures it
+    opens and calls fail fast. Once `cooldown` seconds pass it is
+    half-open: a single call is let through as a probe, and its outcome
+    closes the breaker or reopens it for another cooldown. Callers check
+    allow() when choosing a worker and acquire() just before calling it.
+    """
+
+    def __init__(self, threshold: int = BREAKER_THRESHOLD, cooldown: float = BREAKER_COOLDOWN):
+        self.threshold = threshold
+        self.cooldown = cooldown
+        self.failures = 0
+        self.opened_at: Optional[float] = None
+        self.probe_started_at: Optional[float] = None
+
+    def state(self, now: Optional[float] = None) -> str:
+        if self.opened_at is None:
+            return "closed"
+        if now is None:
+            now = time.time()
+        return "half_open" if now - self.opened_at >= self.cooldown else "open"
+
+    def allow(self, now: Optional[float] = None) -> bool:
+        """True if a call may be attempted now, without claiming the probe"""
+        if now is None:
+            now = time.time()
+        state = self.state(now)
+        if state == "half_open":
+            return not self.probe_in_flight(now)
+        return state == "closed"
+
+    def acquire(self, now: Optional[float] = None) -> bool:
+        """Claim permission for a call; when half-open, only one caller gets it"""
+        if now is None:
+            now = time.time()
+        if not self.allow(now):
+            return False
+        if self.state(now) == "half_open":
+            self.probe_started_at = now
+        return True
+
+    def probe_in_flight(self, now: float) -> bool:
+        # A probe whose outcome was never recorded stops blocking after a
+        # cooldown, so the breaker can't wedge half-open
+        return self.probe_started_at is not None and now - self.probe_started_at < self.cooldown
+
+    def retry_after(self, now: Optional[float] = None) -> float:
+        """Seconds until the breaker lets a probe through"""
+        if self.opened_at is None:
+            return 0
+        if now is None:
+            now = time.time()
+        if self.probe_in_flight(now):
+            return self.probe_started_at + self.cooldown - now
+        return max(0.0, self.opened_at + self.cooldown - now)
+
+    def record_success(self):
+        self.failures = 0
+        self.opened_at = None
+        self.probe_started_at = None
+
+    def record_failure(self, now: Optional[float] = None):
+        if now is None:
+            now = time.time()
+        self.failures += 1
+        self.probe_started_at = None
+        # A failed probe reopens immediately, whatever the count
+        if self.opened_at is not None or self.failures >= self.threshold:
+            self.opened_at = now
+
+
+class RejectReason:
+    """Why a worker was not selected for a job (see select_worker_explained)"""
+    UNHEALTHY = "unhealthy"                # Failed its last health check
+    CIRCUIT_OPEN = "circuit_open"          # Breaker open after repeated failures
+    AT_CAPACITY = "at_capacity"            # All max_concurrent_jobs slots in use
+    MAINTENANCE = "maintenance"            # Job might not finish before maintenance
+    NODE_CONSTRAINTS = "node_constraints"  # Labels fail a required/forbidden selector
+    STALE = "stale"                        # State unconfirmed while fresh workers exist
+    STANDBY = "standby"                    # Held in reserve until the queue bursts
+    OUTRANKED = "outranked"                # Eligible, but another worker ranked higher
+
+
+def _selector_list(value: Any) -> List[str]:
+    """Constraint selectors may be given as one string or a list"""
+    if value is None:
+        return []
+    if isinstance(value, str):
+        return [value]
+    if not isinstance(value, list):
+        raise ValueError("node_constraints selectors must be a string or a list of strings")
+    return value
+
+
+def validate_node_constraints(constraints: Dict):
+    """Raise ValueError if node_constraints is malformed"""
+    if not isinstance(constraints, dict):
+        raise ValueError("node_constraints must be an object")
+    unknown = set(constraints) - {"required", "forbidden", "preferred"}
+    if unknown:
+        raise ValueError(f"Unknown node_constraints keys: {', '.join(sorted(unknown))}")
+    for key in ("required", "forbidden", "preferred"):
+        for selector in _selector_list(constraints.get(key)):
+            if not isinstance(selector, str):
+                raise ValueError(f"node_constraints.{key} must be selector strings")
+            match_labels({}, selector)  # Raises on a malformed selector
+
+
+def preference_score(worker: "Worker", constraints: Dict) -> float:
+    """Fraction of preferred selectors the worker's labels match (0 if none given)"""
+    preferred = _selector_list(constraints.get("preferred"))
+    if not preferred:
+        return 0.0
+    return sum(match_labels(worker.labels, s) for s in preferred) / len(preferred)
+
+
+def constraint_violation(worker: "Worker", constraints: Optional[Dict]) -> Optional[str]:
+    """The first hard node_constraints selector a worker fails, or None"""
+    constraints = constraints or {}
+    for selector in _selector_list(constraints.get("required")):
+        if not match_labels(worker.labels, selector):
+            return f"does not match required selector {selector!r}"
+    for selector in _selector_list(constraints.get("forbidden")):
+        if match_labels(worker.labels, selector):
+            return f"matches forbidden selector {selector!r}"
+    return None
+
+
+def apply_node_constraints(candidates: List["Worker"],
+                           constraints: Optional[Dict]) -> Tuple[List["Worker"], Dict[str, float]]:
+    """
+    Apply a job's node_constraints to candidate workers.
+
+    Hard constraints filter: a worker must match every `required` selector
+    and no `forbidden` one. Soft `preferred` selectors only score the
+    survivors (see preference_score); ranking uses the score through
+    compare_preference. Returns the filtered workers, in their original
+    order, and their scores by worker ID.
+    """
+    constraints = constraints or {}
+    filtered = [w for w in candidates if constraint_violation(w, constraints) is None]
+    scores = {w.worker_id: preference_score(w, constraints) for w in filtered}
+    return filtered, scores
+
 
 @dataclass
 class Worker:
@@ -33,6 +418,64 @@ class Worker:
     is_healthy: bool = False
     active_jobs: int = 0
     max_concurrent_jobs: int = 4
+    labels: Dict[str, str] = field(default_factory=dict)  # Node attributes (region, spot, ...)
+    standby: bool = False       # Warm reserve, only given jobs during bursts
+    breaker: Breaker = field(default_factory=Breaker)
+    maintenance_at: Optional[float] = None      # Start of scheduled maintenance (epoch seconds)
+    maintenance_duration: float = 0
+    declined_jobs: int = 0      # Submissions the worker turned away, ever
+    abandoned_jobs: int = 0     # Accepted jobs not delivered by the signed deadline
+    decline_times: Deque[float] = field(default_factory=collections.deque)  # Within DECLINE_WINDOW
+
+    def record_decline(self, now: Optional[float] = None):
+        self.declined_jobs += 1
+        self.decline_times.append(time.time() if now is None else now)
+
+    def recent_declines(self, now: Optional[float] = None) -> int:
+        """Declines within the last DECLINE_WINDOW seconds"""
+        if now is None:
+            now = time.time()
+        while self.decline_times and now - self.decline_times[0] >= DECLINE_WINDOW:
+            self.decline_times.popleft()
+        return len(self.decline_times)
+
+    def penalty(self, now: Optional[float] = None) -> int:
+        """Reliability penalty used in ranking (see ABANDON_PENALTY)"""
+        return self.recent_declines(now) + ABANDON_PENALTY * self.abandoned_jobs
+
+    def schedule_maintenance(self, at: float, duration: float):
+        """Drain ahead of `at` and stay offline for `duration` seconds"""
+        if duration <= 0:
+            raise ValueError("maintenance duration must be positive")
+        self.maintenance_at = at
+        self.maintenance_duration = duration
+
+    def in_maintenance(self, now: Optional[float] = None) -> bool:
+        """True during the maintenance window; clears the schedule once it has passed"""
+        if self.maintenance_at is None:
+            return False
+        if now is None:
+            now = time.time()
+        if now >= self.maintenance_at + self.maintenance_duration:
+            self.maintenance_at = None
+            self.maintenance_duration = 0
+            return False
+        return now >= self.maintenance_at
+
+    def can_finish_before_maintenance(self, runtime: float, now: Optional[float] = None) -> bool:
+        """True if a job that may run `runtime` seconds ends before the next window"""
+        if now is None:
+            now = time.time()
+        if self.in_maintenance(now):
+            return False
+        return self.maintenance_at is None or now + runtime <= self.maintenance_at
+
+    def capabilities_stale(self, now: Optional[float] = None,
+                           max_age: float = CAPABILITY_MAX_AGE) -> bool:
+        """True if the last successful health check is older than max_age"""
+        if now is None:
+            now = time.time()
+        return now - self.last_health_check > max_age
 
 
 @dataclass
@@ -43,6 +486,104 @@ class PoolJob:
     status: str = "queued"  # queued, dispatched, running, completed, failed
     submitted_at: float = 0
     completed_at: float = 0
+    labels: Dict[str, str] = field(default_factory=dict)  # Metadata only, not part of job identity
+    pinned_worker: Optional[str] = None  # Run only on this worker (debugging reproducibility)
+    error: Optional[str] = None          # Why the pool failed the job itself
+    acceptance: Optional[Dict] = None    # Worker's signed commitment from /submit
+    rejected_workers: Dict[str, Dict[str, str]] = field(default_factory=dict)  # Last selection's losers
+    remote_job_id: Optional[str] = None  # Job ID on the worker, once dispatched
+    manifest: Dict[str, Any] = field(default_factory=dict)  # As forwarded to workers
+    files_data: Optional[bytes] = field(default=b"", repr=False)  # Project archive; None once released
+
+    def clone(self, job_id: Optional[str] = None, reset: bool = True,
+              now: Optional[float] = None, timeout: Optional[float] = None) -> "PoolJob":
+        """
+        Deep copy of this job, for resubmitting it or using it as a template.
+
+        Nested state (labels, acceptance, rejected_workers, and the manifest
+        with its args, env and constraints) is copied too, so changing the
+        clone never changes the original. With reset (the default) the clone
+        is a fresh submission: queued, unassigned, without result, error or
+        acceptance, and submitted now. Placement intent (labels,
+        pinned_worker) and the manifest are kept either way; timeout
+        replaces the manifest's, to give a resubmission more time. Jobs are
+        keyed by ID, so a clone that will be queued needs a new job_id.
+        """
+        cloned = copy.deepcopy(self)
+        if job_id is not None:
+            cloned.job_id = job_id
+        if timeout is not None:
+            cloned.manifest["timeout"] = timeout
+        if reset:
+            cloned.worker_id = None
+            cloned.remote_job_id = None
+            cloned.status = "queued"
+            cloned.submitted_at = time.time() if now is None else now
+            cloned.completed_at = 0
+            cloned.error = None
+            cloned.acceptance = None
+            cloned.rejected_workers = {}
+        return cloned
+
+
+@dataclass
+class Event:
+    """A single pool state change"""
+    seq: int
+    type: str  # job_submitted, job_dispatched, job_requeued, job_status, worker_health
+    job_id: Optional[str]
+    payload: Dict[str, Any]
+    time: float
+
+
+class EventLog:
+    """
+    Ordered stream of pool events.
+
+    Sequence numbers start at 1 and increase by one per event, so a consumer
+    that remembers the last seq it saw can replay from the next one with no
+    gaps, as long as it is still inside the ring buffer.
+    """
+
+    def __init__(self, capacity: int = EVENT_BUFFER_SIZE):
+        self.buffer: Deque[Event] = collections.deque(maxlen=capacity)
+        self.next_seq = 1
+        self.subscribers: List[asyncio.Queue] = []
+
+    def publish(self, type: str, job_id: Optional[str] = None, **payload) -> Event:
+        """Record an event and deliver it to live subscribers"""
+        event = Event(seq=self.next_seq, type=type, job_id=job_id,
+                      payload=payload, time=time.time())
+        self.next_seq += 1
+        self.buffer.append(event)
+
+        for queue in list(self.subscribers):
+            try:
+                queue.put_nowait(event)
+            except asyncio.QueueFull:
+                # Slow consumer: drop it rather than block the pool; it can
+                # resume with replay() from its last seq
+                self.subscribers.remove(queue)
+        return event
+
+    @property
+    def oldest_seq(self) -> int:
+        """Seq of the oldest event still buffered (next_seq if empty)"""
+        return self.buffer[0].seq if self.buffer else self.next_seq
+
+    def replay(self, from_seq: int = 1) -> List[Event]:
+        """Return buffered events with seq >= from_seq, in order"""
+        return [event for event in self.buffer if event.seq >= from_seq]
+
+    def subscribe(self, max_pending: int = EVENT_BUFFER_SIZE) -> asyncio.Queue:
+        """Return a queue that receives every event published from now on"""
+        queue: asyncio.Queue = asyncio.Queue(maxsize=max_pending)
+        self.subscribers.append(queue)
+        return queue
+
+    def unsubscribe(self, queue: asyncio.Queue):
+        if queue in self.subscribers:
+            self.subscribers.remove(queue)
 
 
 class TrustedPoolCoordinator:
@@ -55,17 +596,25 @@ class TrustedPoolCoordinator:
     - Health checking ensures worker availability
     """
 
-    def __init__(self, workers_config: List[Dict]):
+    def __init__(self, workers_config: List[Dict], rand: Optional[RandSource] = None,
+                 capability_max_age: float = CAPABILITY_MAX_AGE,
+                 standby_queue_depth: int = STANDBY_QUEUE_DEPTH):
+        self.rand: RandSource = rand or SystemRandSource()
+        self.capability_max_age = capability_max_age
+        self.standby_queue_depth = standby_queue_depth
         self.workers: Dict[str, Worker] = {}
         self.jobs: Dict[str, PoolJob] = {}
         self.job_queue: asyncio.Queue = asyncio.Queue()
+        self.events = EventLog()
 
         # Load worker allowlist
         for worker_cfg in workers_config:
             worker = Worker(
                 worker_id=worker_cfg["worker_id"],
                 endpoint=worker_cfg["endpoint"],
-                max_concurrent_jobs=worker_cfg.get("max_concurrent_jobs", 4)
+                max_concurrent_jobs=worker_cfg.get("max_concurrent_jobs", 4),
+                labels={str(k): str(v) for k, v in worker_cfg.get("labels", {}).items()},
+                standby=bool(worker_cfg.get("standby", False))
             )
             self.workers[worker.worker_id] = worker
             logger.info(f"Added trusted worker: {worker.worker_id[:16]}... at {worker.endpoint}")
@@ -95,32 +644,163 @@ class TrustedPoolCoordinator:
         """Periodically check worker health"""
         while True:
             for worker in self.workers.values():
+                was_healthy = worker.is_healthy
                 await self.health_check_worker(worker)
+                if worker.is_healthy != was_healthy:
+                    self.events.publish("worker_health", worker_id=worker.worker_id,
+                                        healthy=worker.is_healthy)
+            self.release_job_files()
             await asyncio.sleep(30)  # Check every 30 seconds
 
-    def get_available_worker(self) -> Optional[Worker]:
+    def in_burst(self) -> bool:
+        """True while the queue is deep enough to bring in standby workers"""
+        return self.job_queue.qsize() >= self.standby_queue_depth
+
+    def get_available_worker(self, job_id: str = "", manifest: Optional[Dict] = None) -> Optional[Worker]:
         """Find an available healthy worker"""
-        available = [
-            w for w in self.workers.values()
-            if w.is_healthy and w.active_jobs < w.max_concurrent_jobs
-        ]
+        return self.select_worker_explained(job_id, manifest)[0]
+
+    def select_worker_explained(self, job_id: str = "", manifest: Optional[Dict] = None
+                                ) -> Tuple[Optional[Worker], Dict[str, Dict[str, str]]]:
+        """
+        Select a worker as get_available_worker does, and say why every other
+        worker lost.
+
+        Returns (worker, rejected) where rejected maps each worker ID not
+        chosen to {"reason": RejectReason.*, "detail": ...}. Filters are
+        checked in dispatch order, so each worker gets the first one it
+        fails; workers that pass them all lose on ranking or staleness.
+
+        Standby workers are skipped until the pool is bursting (see
+        in_burst); during a burst they are tried first, since they are idle
+        and warm, and regular workers only take jobs when no standby can.
+        """
+        # Jobs run up to their timeout; a worker only takes one that is sure
+        # to end before its next maintenance window
+        runtime = (manifest or {}).get("timeout", DEFAULT_JOB_TIMEOUT)
+        constraints = (manifest or {}).get("node_constraints")
+        now = time.time()
+        burst = self.in_burst()
+        rejected: Dict[str, Dict[str, str]] = {}
+
+        def reject(worker: Worker, reason: str, detail: str):
+            rejected[worker.worker_id] = {"reason": reason, "detail": detail}
+
+        available = []
+        for w in self.workers.values():
+            violation = constraint_violation(w, constraints)
+            if not w.is_healthy:
+                reject(w, RejectReason.UNHEALTHY, "failed its last health check")
+            elif not w.breaker.allow():
+                reject(w, RejectReason.CIRCUIT_OPEN,
+                       f"circuit breaker open, retry in {w.breaker.retry_after(now):.0f}s")
+            elif w.active_jobs >= w.max_concurrent_jobs:
+                reject(w, RejectReason.AT_CAPACITY,
+                       f"{w.active_jobs}/{w.max_concurrent_jobs} job slots in use")
+            elif not w.can_finish_before_maintenance(runtime, now):
+                reject(w, RejectReason.MAINTENANCE,
+                       f"a {runtime}s job may not finish before maintenance")
+            elif violation:
+                reject(w, RejectReason.NODE_CONSTRAINTS, violation)
+            elif w.standby and not burst:
+                reject(w, RejectReason.STANDBY,
+                       f"held in reserve until {self.standby_queue_depth} jobs are queued")
+            else:
+                available.append(w)
 
         if not available:
-            return None
-
-        # Return worker with fewest active jobs (load balancing)
-        return min(available, key=lambda w: w.active_jobs)
+            return None, rejected
+
+        if burst:
+            standbys = [w for w in available if w.standby]
+            if standbys:
+                for w in available:
+                    if not w.standby:
+                        reject(w, RejectReason.OUTRANKED, "burst jobs go to standby workers first")
+                available = standbys
+
+        # Epoch-seeded shuffle decides between equally ranked workers;
+        # sorted() is stable, so the shuffle order survives among ties
+        candidates = shuffle_candidates(available, job_id, current_epoch())
+
+        # Rank by default ordering (fewest active jobs first = load balancing)
+        ranked = sorted(candidates, key=functools.cmp_to_key(
+            lambda a, b: compare_workers(a, b, manifest)))
+
+        # Workers whose state we haven't confirmed recently may no longer
+        # have the capacity we think; use them only as a last resort
+        fresh = [w for w in ranked if not w.capabilities_stale(now, self.capability_max_age)]
+        if not fresh:
+            logger.warning(f"Only stale workers available for job {job_id}")
+            chosen = ranked[0]
+        else:
+            chosen = fresh[0]
+
+        fresh_ids = {w.worker_id for w in fresh}
+        for w in ranked:
+            if w is chosen:
+                continue
+            if fresh and w.worker_id not in fresh_ids:
+                reject(w, RejectReason.STALE,
+                       f"last health check {now - w.last_health_check:.0f}s ago")
+            else:
+                reject(w, RejectReason.OUTRANKED, f"ranked below {chosen.worker_id[:16]}...")
+        return chosen, rejected
+
+    def get_pinned_worker(self, job: PoolJob, manifest: Optional[Dict] = None) -> Tuple[Optional[Worker], Optional[str]]:
+        """
+        Resolve a pinned job's worker, bypassing ranking.
+
+        Returns (worker, None) when it can take the job now, (None, None) when
+        it is only busy, and (None, reason) when it can't run the job at all.
+        """
+        worker = self.workers.get(job.pinned_worker)
+        if not worker:
+            return None, f"Pinned worker {job.pinned_worker[:16]}... is not in the pool"
+        if not worker.is_healthy:
+            return None, f"Pinned worker {worker.worker_id[:16]}... is unhealthy"
+        if not worker.breaker.allow():
+            return None, f"Pinned worker {worker.worker_id[:16]}... is unavailable (circuit open)"
+        runtime = (manifest or {}).get("timeout", DEFAULT_JOB_TIMEOUT)
+        if not worker.can_finish_before_maintenance(runtime):
+            return None, f"Pinned worker {worker.worker_id[:16]}... has maintenance scheduled"
+        if worker.active_jobs >= worker.max_concurrent_jobs:
+            return None, None
+        return worker, None
 
     async def dispatch_job(self, job: PoolJob, files_data: bytes, manifest: Dict):
         """Dispatch job to an available worker"""
-        worker = self.get_available_worker()
+        if job.pinned_worker:
+            # A pin must never silently fall back to another worker
+            worker, reason = self.get_pinned_worker(job, manifest)
+            if reason:
+                logger.warning(f"Failing pinned job {job.job_id}: {reason}")
+                job.status = "failed"
+                job.error = reason
+                job.completed_at = time.time()
+                self.events.publish("job_status", job.job_id, status=job.status, error=reason)
+                return
+        else:
+            worker, job.rejected_workers = self.select_worker_explained(job.job_id, manifest)
 
         if not worker:
             logger.warning(f"No available workers for job {job.job_id}")
+            healthy = [w for w in self.workers.values() if w.is_healthy]
+            if healthy and not any(w.breaker.allow() for w in healthy):
+                reason = "dependency unavailable, retry later"
+            else:
+                reason = "no available workers"
+            self.events.publish("job_requeued", job.job_id, reason=reason)
             await asyncio.sleep(5)  # Wait and retry
             await self.job_queue.put((job, files_data, manifest))
             return
 
+        if not worker.breaker.acquire():
+            # Another call took the half-open probe since selection
+            self.events.publish("job_requeued", job.job_id, reason="dependency unavailable, retry later")
+            await self.job_queue.put((job, files_data, manifest))
+            return
+
         try:
             # Forward job to worker
             async with aiohttp.ClientSession() as session:
@@ -130,6 +810,7 @@ class TrustedPoolCoordinator:
 
                 async with session.post(f"{worker.endpoint}/submit", data=data, timeout=aiohttp.ClientTimeout(total=30)) as resp:
                     if resp.status == 200:
+                        worker.breaker.record_success()
                         result = await resp.json()
                         remote_job_id = result.get("job_id")
 
@@ -137,21 +818,80 @@ class TrustedPoolCoordinator:
                         job.status = "dispatched"
                         worker.active_jobs += 1
 
+                        # Keep the signed acceptance as evidence; one signed
+                        # by another key commits nobody
+                        acceptance = result.get("acceptance")
+                        if acceptance and acceptance.get("worker_id") == worker.worker_id:
+                            job.acceptance = acceptance
+                        elif acceptance:
+                            logger.warning(f"Ignoring acceptance for {job.job_id} signed by another worker")
+
                         logger.info(f"Dispatched job {job.job_id} to {worker.worker_id[:16]}... (remote: {remote_job_id})")
 
                         # Store remote job ID for tracking
                         self.jobs[job.job_id].remote_job_id = remote_job_id
+                        self.events.publish("job_dispatched", job.job_id,
+                                            worker_id=worker.worker_id,
+                                            remote_job_id=remote_job_id)
                     else:
+                        # A rejection is the worker answering; only server
+                        # errors count against its breaker
+                        if resp.status >= 500 and resp.status != 503:
+                            worker.breaker.record_failure()
+                        else:
+                            worker.breaker.record_success()
                         logger.error(f"Worker {worker.worker_id[:16]}... rejected job: {resp.status}")
+
+                        # A malformed job fails the same way everywhere
+                        if 400 <= resp.status < 500 and resp.status not in DECLINE_STATUSES | {429}:
+                            try:
+                                detail = (await resp.json()).get("error", "")
+                            except Exception:
+                                detail = ""
+                            job.status = "failed"
+                            job.error = f"worker rejected job as invalid ({resp.status}): {detail}".rstrip(": ")
+                            job.completed_at = time.time()
+                            self.events.publish("job_status", job.job_id, status=job.status, error=job.error)
+                            return
+
+                        if resp.status in DECLINE_STATUSES:
+                            worker.record_decline()
+                        self.events.publish("job_requeued", job.job_id,
+                                            reason=f"worker rejected job: {resp.status}")
                         # Re-queue job
                         await self.job_queue.put((job, files_data, manifest))
 
         except Exception as e:
             logger.error(f"Failed to dispatch job to {worker.worker_id[:16]}...: {e}")
+            worker.breaker.record_failure()
             worker.is_healthy = False
+            self.events.publish("worker_health", worker_id=worker.worker_id, healthy=False)
+            self.events.publish("job_requeued", job.job_id, reason=f"dispatch failed: {e}")
             # Re-queue job
             await self.job_queue.put((job, files_data, manifest))
 
+    def abandon_if_overdue(self, job: PoolJob, now: Optional[float] = None) -> bool:
+        """Fail an accepted job whose committed deadline passed without a result"""
+        if not job.acceptance or job.status in ["completed", "failed"]:
+            return False
+        if now is None:
+            now = time.time()
+        deadline = job.acceptance.get("committed_deadline", 0)
+        if now <= deadline:
+            return False
+
+        worker = self.workers.get(job.worker_id)
+        if worker:
+            worker.abandoned_jobs += 1
+        job.status = "failed"
+        job.error = "worker missed its accepted deadline"
+        job.completed_at = now
+        logger.warning(f"Job {job.job_id} abandoned by {job.worker_id[:16]}... after accepting it")
+        self.events.publish("job_abandoned", job.job_id, worker_id=job.worker_id,
+                            committed_deadline=deadline)
+        self.events.publish("job_status", job.job_id, status=job.status, error=job.error)
+        return True
+
     async def job_dispatcher_loop(self):
         """Process queued jobs and dispatch to workers"""
         while True:
@@ -160,23 +900,84 @@ class TrustedPoolCoordinator:
 
     async def submit_job(self, files_data: bytes, manifest: Dict) -> str:
         """Submit a new job to the pool"""
-        import uuid
-        job_id = f"pool-{uuid.uuid4().hex[:16]}"
+        labels = manifest.get("labels", {})
+        if not isinstance(labels, dict):
+            raise ValueError("labels must be an object")
+        if any(isinstance(v, (dict, list)) for v in labels.values()):
+            raise ValueError("label values must be scalars")
+
+        # Pool-level field; sandrun never sees it
+        pinned_worker = manifest.pop("pin_worker", None)
+        if pinned_worker is not None and pinned_worker not in self.workers:
+            raise ValueError(f"pin_worker is not a worker in this pool: {pinned_worker}")
+
+        # A job no allowlisted worker could ever satisfy would wait forever
+        constraints = manifest.get("node_constraints")
+        if constraints is not None:
+            validate_node_constraints(constraints)
+            if not apply_node_constraints(list(self.workers.values()), constraints)[0]:
+                raise ValueError("No worker in the pool satisfies node_constraints")
+
+        job_id = f"pool-{self.rand.bytes(8).hex()}"
 
         job = PoolJob(
             job_id=job_id,
             status="queued",
-            submitted_at=time.time()
+            submitted_at=time.time(),
+            labels={str(k): str(v) for k, v in labels.items()},
+            pinned_worker=pinned_worker,
+            manifest=manifest,
+            files_data=files_data
         )
-        job.remote_job_id = None  # Will be set when dispatched
         self.jobs[job_id] = job
 
         # Queue for dispatching
         await self.job_queue.put((job, files_data, manifest))
 
+        self.events.publish("job_submitted", job_id, labels=job.labels)
         logger.info(f"Queued job {job_id}")
         return job_id
 
+    async def resubmit_job(self, job_id: str, timeout: Optional[float] = None) -> Optional[str]:
+        """Queue a fresh copy of a job under a new ID; None if the job is unknown"""
+        original = self.jobs.get(job_id)
+        if not original:
+            return None
+        if original.files_data is None:
+            raise ValueError(f"Files for {job_id} are no longer kept; submit it again")
+        if timeout is not None and (isinstance(timeout, bool) or
+                                    not isinstance(timeout, (int, float)) or timeout <= 0):
+            raise ValueError("timeout must be a positive number of seconds")
+
+        job = original.clone(job_id=f"pool-{self.rand.bytes(8).hex()}", timeout=timeout)
+        self.jobs[job.job_id] = job
+        await self.job_queue.put((job, job.files_data, job.manifest))
+
+        self.events.publish("job_submitted", job.job_id, labels=job.labels,
+                            resubmitted_from=job_id)
+        logger.info(f"Queued job {job.job_id} (resubmission of {job_id})")
+        return job.job_id
+
+    def release_job_files(self, now: Optional[float] = None) -> int:
+        """
+        Drop uploaded files that are past their resubmission window.
+
+        Returns how many jobs had their files released. Queued copies keep
+        their own reference, so releasing never stops a pending dispatch.
+        """
+        if now is None:
+            now = time.time()
+        released = 0
+        for job in self.jobs.values():
+            if job.files_data is None:
+                continue
+            finished = job.status in ["completed", "failed"] and job.completed_at
+            if ((finished and now - job.completed_at >= RESUBMIT_WINDOW) or
+                    now - job.submitted_at >= FILES_MAX_AGE):
+                job.files_data = None
+                released += 1
+        return released
+
     async def get_job_status(self, job_id: str) -> Optional[Dict]:
         """Get status of a job in the pool"""
         if job_id not in self.jobs:
@@ -185,9 +986,12 @@ class TrustedPoolCoordinator:
         job = self.jobs[job_id]
 
         # If job is dispatched, fetch status from worker
+        unavailable = None
         if job.status in ["dispatched", "running"] and job.worker_id and hasattr(job, 'remote_job_id'):
             worker = self.workers.get(job.worker_id)
-            if worker:
+            if worker and not worker.breaker.acquire():
+                unavailable = DependencyUnavailable(worker.worker_id, worker.breaker.retry_after())
+            elif worker:
                 try:
                     async with aiohttp.ClientSession() as session:
                         async with session.get(
@@ -195,16 +999,21 @@ class TrustedPoolCoordinator:
                             timeout=aiohttp.ClientTimeout(total=10)
                         ) as resp:
                             if resp.status == 200:
+                                worker.breaker.record_success()
                                 worker_status = await resp.json()
 
                                 # Update local job status
+                                previous_status = job.status
                                 job.status = worker_status.get("status", job.status)
+                                if job.status != previous_status:
+                                    self.events.publish("job_status", job_id, status=job.status)
+                                self.abandon_if_overdue(job)
 
                                 if job.status in ["completed", "failed"]:
                                     worker.active_jobs = max(0, worker.active_jobs - 1)
                                     job.completed_at = time.time()
 
-                                return {
+                                status = {
                                     "job_id": job_id,
                                     "pool_status": job.status,
                                     "worker_id": job.worker_id,
@@ -212,21 +1021,60 @@ class TrustedPoolCoordinator:
                                     "submitted_at": job.submitted_at,
                                     "completed_at": job.completed_at if job.status in ["completed", "failed"] else None
                                 }
+                                if job.error:
+                                    status["error"] = job.error
+                                if job.acceptance:
+                                    status["acceptance"] = job.acceptance
+                                return status
+                            elif resp.status >= 500:
+                                worker.breaker.record_failure()
+                            else:
+                                worker.breaker.record_success()
 
                 except Exception as e:
+                    worker.breaker.record_failure()
                     logger.error(f"Failed to get status from worker: {e}")
 
+            # An unreachable worker still owes what it accepted
+            if self.abandon_if_overdue(job):
+                if worker:
+                    worker.active_jobs = max(0, worker.active_jobs - 1)
+                job.completed_at = time.time()
+                unavailable = None
+
         # Return local status
-        return {
+        status = {
             "job_id": job_id,
             "pool_status": job.status,
             "worker_id": job.worker_id,
             "submitted_at": job.submitted_at,
             "completed_at": job.completed_at if job.status in ["completed", "failed"] else None
         }
+        if job.error:
+            status["error"] = job.error
+        if job.acceptance:
+            status["acceptance"] = job.acceptance
+        if unavailable:
+            status["error"] = str(unavailable)
+            status["retry_after"] = unavailable.retry_after
+        return status
+
+    def query_jobs(self, selector: str = "") -> List[Dict]:
+        """List jobs whose labels match a selector (see match_labels)"""
+        return [
+            {
+                "job_id": job.job_id,
+                "pool_status": job.status,
+                "worker_id": job.worker_id,
+                "labels": job.labels,
+                "submitted_at": job.submitted_at
+            }
+            for job in self.jobs.values()
+            if match_labels(job.labels, selector)
+        ]
 
     async def get_job_output(self, job_id: str, output_path: str) -> Optional[bytes]:
-        """Get output file from worker"""
+        """Get output file from worker; raises DependencyUnavailable if its breaker is open"""
         if job_id not in self.jobs:
             return None
 
@@ -238,6 +1086,8 @@ class TrustedPoolCoordinator:
         worker = self.workers.get(job.worker_id)
         if not worker:
             return None
+        if not worker.breaker.acquire():
+            raise DependencyUnavailable(worker.worker_id, worker.breaker.retry_after())
 
         try:
             async with aiohttp.ClientSession() as session:
@@ -245,9 +1095,14 @@ class TrustedPoolCoordinator:
                     f"{worker.endpoint}/outputs/{job.remote_job_id}/{output_path}",
                     timeout=aiohttp.ClientTimeout(total=60)
                 ) as resp:
+                    if resp.status >= 500:
+                        worker.breaker.record_failure()
+                    else:
+                        worker.breaker.record_success()
                     if resp.status == 200:
                         return await resp.read()
         except Exception as e:
+            worker.breaker.record_failure()
             logger.error(f"Failed to get output from worker: {e}")
 
         return None
@@ -281,11 +1136,35 @@ async def handle_submit(request: web.Request) -> web.Response:
             "status": "queued"
         })
 
+    except ValueError as e:
+        return web.json_response({"error": str(e)}, status=400)
     except Exception as e:
         logger.error(f"Submit error: {e}")
         return web.json_response({"error": str(e)}, status=500)
 
 
+async def handle_resubmit(request: web.Request) -> web.Response:
+    """Handle resubmission of a job, with an optional {"timeout": <seconds>}"""
+    coordinator: TrustedPoolCoordinator = request.app['coordinator']
+
+    try:
+        body = await request.json() if request.can_read_body else {}
+        if not isinstance(body, dict):
+            raise ValueError("request body must be an object")
+        job_id = await coordinator.resubmit_job(request.match_info['job_id'], body.get("timeout"))
+    except ValueError as e:
+        return web.json_response({"error": str(e)}, status=400)
+
+    if not job_id:
+        return web.json_response({"error": "Job not found"}, status=404)
+
+    return web.json_response({
+        "job_id": job_id,
+        "status": "queued",
+        "resubmitted_from": request.match_info['job_id']
+    })
+
+
 async def handle_status(request: web.Request) -> web.Response:
     """Handle status request"""
     coordinator: TrustedPoolCoordinator = request.app['coordinator']
@@ -299,13 +1178,110 @@ async def handle_status(request: web.Request) -> web.Response:
     return web.json_response(status)
 
 
+async def handle_selection(request: web.Request) -> web.Response:
+    """Handle a request for why workers were not selected for a job"""
+    coordinator: TrustedPoolCoordinator = request.app['coordinator']
+    job = coordinator.jobs.get(request.match_info['job_id'])
+    if not job:
+        return web.json_response({"error": "Job not found"}, status=404)
+
+    return web.json_response({
+        "job_id": job.job_id,
+        "worker_id": job.worker_id,
+        "pinned_worker": job.pinned_worker,
+        "rejected": job.rejected_workers
+    })
+
+
+async def handle_jobs(request: web.Request) -> web.Response:
+    """Handle job listing, optionally filtered by ?selector="""
+    coordinator: TrustedPoolCoordinator = request.app['coordinator']
+    selector = request.query.get('selector', '')
+
+    try:
+        jobs = coordinator.query_jobs(selector)
+    except ValueError as e:
+        return web.json_response({"error": str(e)}, status=400)
+
+    return web.json_response({"total_jobs": len(jobs), "jobs": jobs})
+
+
+async def handle_events(request: web.Request) -> web.Response:
+    """Handle event replay from ?from=<seq>"""
+    coordinator: TrustedPoolCoordinator = request.app['coordinator']
+    try:
+        from_seq = int(request.query.get('from', '1'))
+    except ValueError:
+        return web.json_response({"error": "from must be an integer"}, status=400)
+
+    log = coordinator.events
+    return web.json_response({
+        "events": [asdict(event) for event in log.replay(from_seq)],
+        "next_seq": log.next_seq,
+        # Events before oldest_seq have left the buffer
+        "truncated": from_seq < log.oldest_seq
+    })
+
+
+async def handle_event_stream(request: web.Request) -> web.StreamResponse:
+    """Stream live events as server-sent events, resuming after Last-Event-ID"""
+    coordinator: TrustedPoolCoordinator = request.app['coordinator']
+    try:
+        if 'Last-Event-ID' in request.headers:
+            from_seq = int(request.headers['Last-Event-ID']) + 1
+        else:
+            from_seq = int(request.query.get('from', '0'))
+    except ValueError:
+        return web.json_response({"error": "from must be an integer"}, status=400)
+
+    log = coordinator.events
+    # Subscribe before replaying so nothing published in between is missed
+    queue = log.subscribe()
+    response = web.StreamResponse(headers={"Content-Type": "text/event-stream",
+                                           "Cache-Control": "no-cache"})
+    await response.prepare(request)
+
+    async def send(event: Event):
+        data = json.dumps(asdict(event))
+        await response.write(f"id: {event.seq}\nevent: {event.type}\ndata: {data}\n\n".encode())
+
+    try:
+        last_seq = from_seq - 1 if from_seq else log.next_seq - 1
+        if from_seq:
+            for event in log.replay(from_seq):
+                await send(event)
+                last_seq = event.seq
+        while True:
+            try:
+                event = await asyncio.wait_for(queue.get(), EVENT_STREAM_KEEPALIVE)
+            except asyncio.TimeoutError:
+                if queue not in log.subscribers:
+                    # Dropped as a slow consumer; the client reconnects
+                    # with Last-Event-ID and replays the gap
+                    break
+                await response.write(b": keepalive\n\n")
+                continue
+            if event.seq > last_seq:
+                await send(event)
+                last_seq = event.seq
+    except ConnectionResetError:
+        pass
+    finally:
+        log.unsubscribe(queue)
+    return response
+
+
 async def handle_output(request: web.Request) -> web.Response:
     """Handle output download"""
     coordinator: TrustedPoolCoordinator = request.app['coordinator']
     job_id = request.match_info['job_id']
     output_path = request.match_info['path']
 
-    data = await coordinator.get_job_output(job_id, output_path)
+    try:
+        data = await coordinator.get_job_output(job_id, output_path)
+    except DependencyUnavailable as e:
+        return web.json_response({"error": str(e), "retry_after": e.retry_after}, status=503,
+                                 headers={"Retry-After": str(int(e.retry_after) + 1)})
 
     if not data:
         return web.Response(status=404, text="Output not found")
@@ -313,6 +1289,30 @@ async def handle_output(request: web.Request) -> web.Response:
     return web.Response(body=data, content_type='application/octet-stream')
 
 
+async def handle_maintenance(request: web.Request) -> web.Response:
+    """Schedule maintenance for a worker: {"at": <epoch seconds>, "duration": <seconds>}"""
+    coordinator: TrustedPoolCoordinator = request.app['coordinator']
+    worker = coordinator.workers.get(request.match_info['worker_id'])
+    if not worker:
+        return web.json_response({"error": "Worker not found"}, status=404)
+
+    try:
+        body = await request.json()
+        worker.schedule_maintenance(float(body["at"]), float(body["duration"]))
+    except (ValueError, KeyError, TypeError) as e:
+        return web.json_response({"error": f"Invalid maintenance request: {e}"}, status=400)
+
+    coordinator.events.publish("worker_maintenance", worker_id=worker.worker_id,
+                               at=worker.maintenance_at, duration=worker.maintenance_duration)
+    logger.info(f"Worker {worker.worker_id[:16]}... maintenance at {worker.maintenance_at} "
+                f"for {worker.maintenance_duration}s")
+    return web.json_response({
+        "worker_id": worker.worker_id,
+        "maintenance_at": worker.maintenance_at,
+        "maintenance_duration": worker.maintenance_duration
+    })
+
+
 async def handle_pool_status(request: web.Request) -> web.Response:
     """Handle pool status request"""
     coordinator: TrustedPoolCoordinator = request.app['coordinator']
@@ -322,10 +1322,20 @@ async def handle_pool_status(request: web.Request) -> web.Response:
         workers_status.append({
             "worker_id": worker.worker_id,
             "endpoint": worker.endpoint,
+            "labels": worker.labels,
+            "standby": worker.standby,
             "is_healthy": worker.is_healthy,
             "active_jobs": worker.active_jobs,
             "max_concurrent_jobs": worker.max_concurrent_jobs,
-            "last_health_check": worker.last_health_check
+            "last_health_check": worker.last_health_check,
+            "capabilities_stale": worker.capabilities_stale(max_age=coordinator.capability_max_age),
+            "breaker_state": worker.breaker.state(),
+            "in_maintenance": worker.in_maintenance(),
+            "maintenance_at": worker.maintenance_at,
+            "maintenance_duration": worker.maintenance_duration,
+            "declined_jobs": worker.declined_jobs,
+            "recent_declines": worker.recent_declines(),
+            "abandoned_jobs": worker.abandoned_jobs
         })
 
     return web.json_response({
@@ -333,6 +1343,7 @@ async def handle_pool_status(request: web.Request) -> web.Response:
         "healthy_workers": sum(1 for w in coordinator.workers.values() if w.is_healthy),
         "total_jobs": len(coordinator.jobs),
         "queued_jobs": coordinator.job_queue.qsize(),
+        "bursting": coordinator.in_burst(),
         "workers": workers_status
     })
 
@@ -359,6 +1370,10 @@ def main():
     parser = argparse.ArgumentParser(description="Trusted Pool Coordinator")
     parser.add_argument("--port", type=int, default=9000, help="Port to listen on")
     parser.add_argument("--workers", type=str, required=True, help="Workers config file (JSON)")
+    parser.add_argument("--capability-max-age", type=float, default=CAPABILITY_MAX_AGE,
+                        help="Seconds before a worker's health data counts as stale")
+    parser.add_argument("--standby-queue-depth", type=int, default=STANDBY_QUEUE_DEPTH,
+                        help="Queued jobs at which standby workers start taking jobs")
     args = parser.parse_args()
 
     # Load workers config
@@ -366,7 +1381,9 @@ def main():
         workers_config = json.load(f)
 
     # Create coordinator
-    coordinator = TrustedPoolCoordinator(workers_config)
+    coordinator = TrustedPoolCoordinator(workers_config,
+                                         capability_max_age=args.capability_max_age,
+                                         standby_queue_depth=args.standby_queue_depth)
 
     # Create web app
     app = web.Application(client_max_size=1024**3)  # 1GB max upload
@@ -375,8 +1392,14 @@ def main():
     # Routes
     app.router.add_post('/submit', handle_submit)
     app.router.add_get('/status/{job_id}', handle_status)
+    app.router.add_get('/jobs', handle_jobs)
+    app.router.add_get('/jobs/{job_id}/selection', handle_selection)
+    app.router.add_post('/jobs/{job_id}/resubmit', handle_resubmit)
+    app.router.add_get('/events', handle_events)
+    app.router.add_get('/events/stream', handle_event_stream)
     app.router.add_get('/outputs/{job_id}/{path:.*}', handle_output)
     app.router.add_get('/pool', handle_pool_status)
+    app.router.add_post('/workers/{worker_id}/maintenance', handle_maintenance)
 
     # Background tasks
     app.on_startup.append(start_background_tasks)
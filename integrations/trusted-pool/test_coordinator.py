@@ -0,0 +1,325 @@
+"""
+Unit tests for the coordinator's scheduling helpers and job bookkeeping.
+
+Run with:
+    pip install -r requirements-test.txt
+    pytest test_coordinator.py
+"""
+
+import time
+
+import pytest
+
+import coordinator as coordinator_module
+from coordinator import (DECLINE_WINDOW, FILES_MAX_AGE, RESUBMIT_WINDOW, Breaker, PoolJob,
+                         TrustedPoolCoordinator, Worker, _split_selector, compare_workers,
+                         match_labels, validate_node_constraints)
+
+
+# ============================================================================
+# Label selectors
+# ============================================================================
+
+def test_split_selector_keeps_set_values_together():
+    assert _split_selector("team=ml, tier in (a,b),  gpu!=none") == [
+        "team=ml", "tier in (a,b)", "gpu!=none"]
+
+
+def test_split_selector_drops_empty_terms():
+    assert _split_selector(" , team=ml,,") == ["team=ml"]
+    assert _split_selector("") == []
+
+
+@pytest.mark.parametrize("selector", ["tier in (a,b", "tier in a,b)", "x=(1"])
+def test_split_selector_rejects_unbalanced_parentheses(selector):
+    with pytest.raises(ValueError, match="Unbalanced"):
+        _split_selector(selector)
+
+
+@pytest.mark.parametrize("selector,expected", [
+    ("", True),
+    ("team=ml", True),
+    ("team==ml", True),
+    ("team=web", False),
+    ("team!=web", True),
+    ("team!=ml", False),
+    ("region!=eu", True),            # missing label is "different"
+    ("tier in (gold, silver)", True),
+    ("tier in (bronze)", False),
+    ("region in (eu)", False),       # missing label is in no set
+    ("tier notin (bronze)", True),
+    ("tier notin (gold)", False),
+    ("region notin (eu)", True),
+    ("team=ml,tier in (gold)", True),
+    ("team=ml,tier in (bronze)", False),
+])
+def test_match_labels(selector, expected):
+    labels = {"team": "ml", "tier": "gold"}
+    assert match_labels(labels, selector) is expected
+
+
+@pytest.mark.parametrize("selector", ["team", "team=ml=x", "tier within (a)"])
+def test_match_labels_rejects_malformed_terms(selector):
+    with pytest.raises(ValueError, match="Invalid selector term"):
+        match_labels({"team": "ml"}, selector)
+
+
+@pytest.mark.parametrize("constraints", [
+    {"required": 5},
+    {"required": ["tier=gold", 5]},
+    {"forbidden": {"tier": "bronze"}},
+    {"preferred": None, "required": 1.5},
+    ["tier=gold"],
+])
+def test_validate_node_constraints_rejects_non_string_selectors(constraints):
+    with pytest.raises(ValueError, match="node_constraints"):
+        validate_node_constraints(constraints)
+
+
+def test_validate_node_constraints_accepts_string_or_list():
+    validate_node_constraints({"required": "tier=gold", "preferred": ["region=eu", "gpu!=none"]})
+
+
+@pytest.mark.asyncio
+async def test_submit_job_rejects_malformed_node_constraints(coordinator):
+    with pytest.raises(ValueError, match="node_constraints"):
+        await coordinator.submit_job(b"", {"entrypoint": "main.py", "node_constraints": {"required": 7}})
+    assert not coordinator.jobs
+
+
+# ============================================================================
+# Circuit breaker
+# ============================================================================
+
+def test_breaker_opens_after_threshold_consecutive_failures():
+    breaker = Breaker(threshold=3, cooldown=60)
+    for _ in range(2):
+        breaker.record_failure(now=100)
+    assert breaker.state(now=100) == "closed"
+
+    breaker.record_failure(now=100)
+    assert breaker.state(now=100) == "open"
+    assert not breaker.allow(now=130)
+    assert breaker.retry_after(now=130) == 30
+
+
+def test_breaker_success_resets_failure_count():
+    breaker = Breaker(threshold=3, cooldown=60)
+    breaker.record_failure(now=100)
+    breaker.record_failure(now=100)
+    breaker.record_success()
+    breaker.record_failure(now=100)
+    assert breaker.state(now=100) == "closed"
+
+
+def test_breaker_half_open_probe_closes_or_reopens():
+    breaker = Breaker(threshold=1, cooldown=60)
+    breaker.record_failure(now=100)
+    assert breaker.state(now=160) == "half_open"
+    assert breaker.allow(now=160)
+
+    # A failed probe reopens for a full cooldown from the probe
+    breaker.record_failure(now=160)
+    assert breaker.state(now=200) == "open"
+    assert breaker.retry_after(now=200) == 20
+
+    breaker.record_success()
+    assert breaker.state(now=200) == "closed"
+    assert breaker.retry_after(now=200) == 0
+
+
+def test_breaker_half_open_admits_a_single_probe():
+    breaker = Breaker(threshold=1, cooldown=60)
+    breaker.record_failure(now=100)
+
+    assert breaker.acquire(now=160)
+    assert not breaker.acquire(now=161)
+    assert not breaker.allow(now=161)
+    assert breaker.retry_after(now=170) == 50
+
+    # The probe's result decides for everyone waiting on it
+    breaker.record_success()
+    assert breaker.acquire(now=170) and breaker.acquire(now=170)
+
+
+def test_breaker_unrecorded_probe_expires():
+    breaker = Breaker(threshold=1, cooldown=60)
+    breaker.record_failure(now=100)
+    assert breaker.acquire(now=160)
+    assert breaker.acquire(now=220)
+
+
+# ============================================================================
+# Worker ranking and dispatch
+# ============================================================================
+
+def test_declines_expire_after_window():
+    worker = Worker(worker_id="w1", endpoint="http://localhost:18001")
+    worker.record_decline(now=0)
+    worker.record_decline(now=100)
+    assert worker.penalty(now=100) == 2
+    assert worker.penalty(now=DECLINE_WINDOW) == 1
+    assert worker.penalty(now=DECLINE_WINDOW + 100) == 0
+    assert worker.declined_jobs == 2
+
+
+def test_load_ranks_above_reliability():
+    busy = Worker(worker_id="busy", endpoint="http://a", is_healthy=True, active_jobs=3)
+    declined = Worker(worker_id="declined", endpoint="http://b", is_healthy=True)
+    declined.record_decline()
+    assert compare_workers(declined, busy) == -1
+
+
+class FakeResponse:
+    def __init__(self, status, body):
+        self.status, self.body = status, body
+
+    async def json(self):
+        return self.body
+
+    async def __aenter__(self):
+        return self
+
+    async def __aexit__(self, *exc):
+        return False
+
+
+def fake_session(status, body):
+    class FakeSession:
+        async def __aenter__(self):
+            return self
+
+        async def __aexit__(self, *exc):
+            return False
+
+        def post(self, url, **kwargs):
+            return FakeResponse(status, body)
+    return FakeSession
+
+
+async def dispatch_with_response(coordinator, monkeypatch, status, body):
+    worker = coordinator.workers["w1"]
+    worker.is_healthy = True
+    worker.last_health_check = time.time()
+    monkeypatch.setattr(coordinator_module.aiohttp, "ClientSession", fake_session(status, body))
+    job_id = await coordinator.submit_job(b"archive", {"entrypoint": "main.py"})
+    job, files_data, manifest = await coordinator.job_queue.get()
+    await coordinator.dispatch_job(job, files_data, manifest)
+    return coordinator.jobs[job_id], worker
+
+
+@pytest.mark.asyncio
+async def test_dispatch_fails_job_the_worker_calls_invalid(coordinator, monkeypatch):
+    job, worker = await dispatch_with_response(coordinator, monkeypatch, 422,
+                                               {"error": "Too many output patterns"})
+    assert job.status == "failed"
+    assert job.error == "worker rejected job as invalid (422): Too many output patterns"
+    assert coordinator.job_queue.empty()
+    assert worker.declined_jobs == 0
+
+
+@pytest.mark.asyncio
+@pytest.mark.parametrize("status, declined", [(503, 1), (429, 0), (500, 0)])
+async def test_dispatch_requeues_refusals_and_counts_only_declines(coordinator, monkeypatch,
+                                                                   status, declined):
+    job, worker = await dispatch_with_response(coordinator, monkeypatch, status, {})
+    assert job.status == "queued"
+    assert coordinator.job_queue.qsize() == 1
+    assert worker.declined_jobs == declined
+
+
+# ============================================================================
+# Job submission
+# ============================================================================
+
+@pytest.fixture
+def coordinator():
+    return TrustedPoolCoordinator([{"worker_id": "w1", "endpoint": "http://localhost:18001"}])
+
+
+@pytest.mark.asyncio
+async def test_submit_job_stringifies_labels(coordinator):
+    job_id = await coordinator.submit_job(b"", {"entrypoint": "main.py", "labels": {"run": 7}})
+    assert coordinator.jobs[job_id].labels == {"run": "7"}
+
+
+@pytest.mark.asyncio
+@pytest.mark.parametrize("labels", [["team", "ml"], "team=ml", {"team": {"name": "ml"}}])
+async def test_submit_job_rejects_malformed_labels(coordinator, labels):
+    with pytest.raises(ValueError, match="label"):
+        await coordinator.submit_job(b"", {"entrypoint": "main.py", "labels": labels})
+    assert not coordinator.jobs
+
+
+# ============================================================================
+# Cloning and resubmission
+# ============================================================================
+
+def test_clone_deep_copies_manifest():
+    job = PoolJob(job_id="pool-1", status="failed", worker_id="w1", error="boom",
+                  manifest={"entrypoint": "main.py", "args": ["--n", "1"], "env": {"A": "1"}},
+                  files_data=b"archive")
+    cloned = job.clone(job_id="pool-2", now=100)
+
+    cloned.manifest["args"].append("--extra")
+    cloned.manifest["env"]["A"] = "2"
+    assert job.manifest == {"entrypoint": "main.py", "args": ["--n", "1"], "env": {"A": "1"}}
+    assert cloned.files_data == b"archive"
+    assert (cloned.status, cloned.worker_id, cloned.error, cloned.submitted_at) == ("queued", None, None, 100)
+
+
+def test_clone_timeout_overrides_only_the_copy():
+    job = PoolJob(job_id="pool-1", manifest={"entrypoint": "main.py", "timeout": 60})
+    cloned = job.clone(job_id="pool-2", timeout=600)
+    assert cloned.manifest["timeout"] == 600
+    assert job.manifest["timeout"] == 60
+
+
+@pytest.mark.asyncio
+async def test_resubmit_job_queues_copy_of_files_and_manifest(coordinator):
+    job_id = await coordinator.submit_job(b"archive", {"entrypoint": "main.py", "labels": {"team": "ml"}})
+    coordinator.jobs[job_id].status = "failed"
+    await coordinator.job_queue.get()
+
+    new_id = await coordinator.resubmit_job(job_id, timeout=900)
+
+    assert new_id != job_id
+    assert coordinator.jobs[job_id].status == "failed"
+    job, files_data, manifest = await coordinator.job_queue.get()
+    assert job is coordinator.jobs[new_id]
+    assert job.status == "queued" and job.labels == {"team": "ml"}
+    assert files_data == b"archive"
+    assert manifest == {"entrypoint": "main.py", "labels": {"team": "ml"}, "timeout": 900}
+    assert coordinator.events.replay()[-1].payload["resubmitted_from"] == job_id
+
+
+@pytest.mark.asyncio
+async def test_resubmit_job_unknown_or_bad_timeout(coordinator):
+    assert await coordinator.resubmit_job("pool-missing") is None
+    job_id = await coordinator.submit_job(b"", {"entrypoint": "main.py"})
+    for timeout in [0, -5, "60", True]:
+        with pytest.raises(ValueError, match="timeout"):
+            await coordinator.resubmit_job(job_id, timeout=timeout)
+
+
+def test_release_job_files_after_resubmit_window(coordinator):
+    finished = PoolJob(job_id="pool-done", status="failed", submitted_at=0,
+                       completed_at=100, files_data=b"archive")
+    running = PoolJob(job_id="pool-run", status="dispatched", submitted_at=0, files_data=b"archive")
+    coordinator.jobs = {job.job_id: job for job in [finished, running]}
+
+    assert coordinator.release_job_files(now=100 + RESUBMIT_WINDOW - 1) == 0
+    assert coordinator.release_job_files(now=100 + RESUBMIT_WINDOW) == 1
+    assert finished.files_data is None and running.files_data == b"archive"
+
+    # Files of a job never seen to finish go once they are too old
+    assert coordinator.release_job_files(now=FILES_MAX_AGE) == 1
+    assert running.files_data is None
+
+
+@pytest.mark.asyncio
+async def test_resubmit_job_refuses_released_files(coordinator):
+    job_id = await coordinator.submit_job(b"archive", {"entrypoint": "main.py"})
+    coordinator.jobs[job_id].files_data = None
+    with pytest.raises(ValueError, match="no longer kept"):
+        await coordinator.resubmit_job(job_id)
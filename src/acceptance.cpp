@@ -0,0 +1,55 @@
+#include "acceptance.h"
+#include "constants.h"
+#include <sstream>
+
+namespace sandrun {
+
+JobAcceptance JobAcceptance::build(const std::string& job_id,
+                                   const std::string& job_hash,
+                                   int64_t committed_deadline,
+                                   const WorkerIdentity& signer) {
+    JobAcceptance acceptance;
+    acceptance.job_id = job_id;
+    acceptance.job_hash = job_hash;
+    acceptance.committed_deadline = committed_deadline;
+    acceptance.worker_id = signer.get_worker_id();
+    acceptance.signature = signer.sign(acceptance.signing_data());
+    return acceptance;
+}
+
+int64_t JobAcceptance::deadline_for(int64_t now, int queue_position, int timeout_seconds) {
+    int64_t per_job = static_cast<int64_t>(timeout_seconds) + JOB_START_MARGIN_SECONDS;
+    int64_t in_flight = 1;
+    return now + (static_cast<int64_t>(queue_position) + in_flight) * per_job;
+}
+
+std::string JobAcceptance::signing_data() const {
+    std::ostringstream data;
+    data << ACCEPTANCE_HASH_DOMAIN << ":"
+         << job_id << "|"
+         << job_hash << "|"
+         << committed_deadline << "|"
+         << worker_id;
+    return data.str();
+}
+
+bool JobAcceptance::verify() const {
+    if (worker_id.empty() || signature.empty()) {
+        return false;
+    }
+    return WorkerIdentity::verify(signing_data(), signature, worker_id);
+}
+
+std::string JobAcceptance::to_json() const {
+    std::ostringstream json;
+    json << "{";
+    json << "\"job_id\":\"" << job_id << "\",";
+    json << "\"job_hash\":\"" << job_hash << "\",";
+    json << "\"committed_deadline\":" << committed_deadline << ",";
+    json << "\"worker_id\":\"" << worker_id << "\",";
+    json << "\"signature\":\"" << signature << "\"";
+    json << "}";
+    return json.str();
+}
+
+} // namespace sandrun
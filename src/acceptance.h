@@ -0,0 +1,40 @@
+#pragma once
+
+#include <string>
+#include <cstdint>
+#include "worker_identity.h"
+
+namespace sandrun {
+
+// Signed commitment a worker returns when it accepts a job: it will deliver
+// a result for job_hash by committed_deadline. Unlike a receipt, it is
+// issued before anything runs, so an accepted job that never completes is
+// attributable to the worker that accepted it
+struct JobAcceptance {
+    std::string job_id;             // Worker-local job ID
+    std::string job_hash;           // JobDefinition::calculate_hash()
+    int64_t committed_deadline = 0; // Unix seconds by which the result is due
+    std::string worker_id;          // Signer's public key (base64)
+    std::string signature;          // Ed25519 over signing_data() (base64)
+
+    // Build and sign an acceptance
+    static JobAcceptance build(const std::string& job_id,
+                               const std::string& job_hash,
+                               int64_t committed_deadline,
+                               const WorkerIdentity& signer);
+
+    // Latest time a job can finish when queue_position jobs (this one
+    // included) are queued and one more may already be running: each is
+    // bounded by timeout_seconds plus JOB_START_MARGIN_SECONDS of overhead
+    static int64_t deadline_for(int64_t now, int queue_position, int timeout_seconds);
+
+    // Canonical bytes covered by the signature
+    std::string signing_data() const;
+
+    // Check the signature against worker_id
+    bool verify() const;
+
+    std::string to_json() const;
+};
+
+} // namespace sandrun
@@ -1,6 +1,7 @@
 #pragma once
 
 #include <cstddef>  // for size_t
+#include <cstdint>
 
 namespace sandrun {
 
@@ -9,12 +10,14 @@ constexpr size_t DEFAULT_MEMORY_LIMIT_BYTES = 512 * 1024 * 1024;  // 512MB
 constexpr size_t MAX_OUTPUT_SIZE = 10 * 1024 * 1024;              // 10MB max output
 constexpr size_t MAX_REQUEST_SIZE = 100 * 1024 * 1024;            // 100MB max request
 constexpr size_t MAX_JOB_FILES_SIZE = 100 * 1024 * 1024;         // 100MB max for job files
+constexpr size_t MAX_OUTPUT_PATTERNS = 10000;                    // Default max manifest "outputs" entries
 constexpr size_t TMPFS_SIZE_LIMIT = 100 * 1024 * 1024;           // 100MB tmpfs
 
 // Time limits
 constexpr size_t DEFAULT_CPU_QUOTA_US = 10 * 1000 * 1000;        // 10 CPU seconds
 constexpr size_t DEFAULT_CPU_PERIOD_US = 60 * 1000 * 1000;       // Per 60 seconds
 constexpr int DEFAULT_TIMEOUT_SECONDS = 300;                      // 5 minutes
+constexpr int JOB_START_MARGIN_SECONDS = 30;                      // Executor poll + environment setup, per job
 constexpr int JOB_CLEANUP_AFTER_SECONDS = 60;                     // Auto-delete after 1 minute
 
 // Process limits
@@ -36,6 +39,34 @@ constexpr int RATE_LIMIT_CLEANUP_MINUTES = 60;                   // Cleanup inac
 constexpr size_t PIPE_BUFFER_SIZE = 4096;                        // Read buffer size
 constexpr size_t INITIAL_HTTP_BUFFER = 8192;                     // Initial HTTP buffer
 constexpr size_t SECURE_DELETE_CHUNK = 1024 * 1024;              // 1MB chunks for secure delete
+constexpr size_t OUTPUT_CHUNK_SIZE = 1024 * 1024;                // 1MB chunks for streamed output verification
+
+// Proof limits (checked before hashing so oversized proofs are cheap to reject)
+constexpr size_t MAX_PROOF_CHECKPOINTS = 10000;                  // ~1 checkpoint/sec for ~3 hours
+constexpr size_t MAX_PROOF_FIELD_LENGTH = 1024;                  // Max length of id/hash fields
+constexpr size_t MAX_PROOF_ANNOTATIONS = 64;                     // Max annotation entries per proof
+constexpr size_t MAX_PROOF_ANNOTATION_BYTES = 16 * 1024;         // Max total annotation key+value bytes
+
+// Proof format versions (see ProofOfCompute::canonical_bytes)
+constexpr uint32_t PROOF_VERSION = 2;                            // Version this node writes
+constexpr uint32_t MIN_PROOF_VERSION = 1;                        // Oldest version still verified
+
+// Work-unit weights (see ProofOfCompute::work_units); one unit = one CPU second
+constexpr double GPU_SECOND_WORK_UNITS = 10.0;                   // GPU second vs CPU second
+constexpr double GIB_SECOND_WORK_UNITS = 0.1;                    // Memory held, per GiB per second
+
+// Hash domain separators (prefixed to hash inputs so a digest computed
+// for one purpose can never be replayed as valid in another; the version
+// suffix changes whenever the canonical encoding does)
+constexpr const char* PROOF_V2_HASH_DOMAIN = "sandrun-proof-v2";
+constexpr const char* JOB_HASH_DOMAIN = "sandrun-job-v2";
+constexpr const char* CHECKPOINT_HASH_DOMAIN = "sandrun-checkpoint-v1";
+constexpr const char* CHUNK_TREE_HASH_DOMAIN = "sandrun-chunks-v1";
+constexpr const char* RECEIPT_HASH_DOMAIN = "sandrun-receipt-v1";
+constexpr const char* OUTPUT_HASH_DOMAIN = "sandrun-outputs-v1";
+constexpr const char* DESCRIPTOR_HASH_DOMAIN = "sandrun-descriptor-v1";
+constexpr const char* ENV_LOCK_HASH_DOMAIN = "sandrun-env-lock-v1";
+constexpr const char* ACCEPTANCE_HASH_DOMAIN = "sandrun-acceptance-v1";
 
 // Network
 constexpr int DEFAULT_PORT = 8443;                               // Default server port
@@ -1,9 +1,12 @@
 #include "environment_manager.h"
+#include "file_utils.h"
+#include "constants.h"
 #include <filesystem>
 #include <iostream>
 #include <fstream>
 #include <sstream>
 #include <cstdlib>
+#include <algorithm>
 #include <sys/stat.h>
 
 namespace fs = std::filesystem;
@@ -31,6 +34,27 @@ void EnvironmentManager::register_template(const EnvironmentTemplate& tmpl) {
     std::cout << "[EnvManager] Registered template: " << tmpl.name << std::endl;
 }
 
+bool EnvironmentTemplate::is_pinned() const {
+    return std::all_of(packages.begin(), packages.end(), [](const std::string& pkg) {
+        auto pos = pkg.find("==");
+        return pos != std::string::npos && pos > 0 && pos + 2 < pkg.size();
+    });
+}
+
+std::string EnvironmentTemplate::lock_hash() const {
+    // Install order does not change what ends up installed
+    std::vector<std::string> sorted = packages;
+    std::sort(sorted.begin(), sorted.end());
+    sorted.erase(std::unique(sorted.begin(), sorted.end()), sorted.end());
+
+    std::ostringstream data;
+    data << ENV_LOCK_HASH_DOMAIN << ":" << base_image << "|";
+    for (const auto& pkg : sorted) {
+        data << pkg << "|";
+    }
+    return FileUtils::sha256_string(data.str());
+}
+
 bool EnvironmentManager::has_template(const std::string& name) const {
     std::lock_guard<std::mutex> lock(mutex_);
     return templates_.count(name) > 0;
@@ -45,6 +69,15 @@ std::vector<std::string> EnvironmentManager::list_templates() const {
     return names;
 }
 
+std::optional<std::string> EnvironmentManager::lock_hash(const std::string& name) const {
+    std::lock_guard<std::mutex> lock(mutex_);
+    auto it = templates_.find(name);
+    if (it == templates_.end() || !it->second.is_pinned()) {
+        return std::nullopt;
+    }
+    return it->second.lock_hash();
+}
+
 std::string EnvironmentManager::prepare_environment(
     const std::string& template_name,
     const std::string& job_id
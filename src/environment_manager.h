@@ -6,6 +6,7 @@
 #include <mutex>
 #include <chrono>
 #include <memory>
+#include <optional>
 
 namespace sandrun {
 
@@ -17,6 +18,14 @@ struct EnvironmentTemplate {
     std::string setup_script;              // Optional setup script path
     int max_age_hours = 24;                // Max age before eviction
     bool gpu_enabled = false;              // Whether this env needs GPU
+
+    // True when every package is pinned to an exact version ("name==x.y.z"),
+    // i.e. building the template twice installs the same packages
+    bool is_pinned() const;
+
+    // Hash of base_image and the sorted package list. Jobs name it in their
+    // manifest ("environment_lock") to require exactly this environment.
+    std::string lock_hash() const;
 };
 
 // Cached environment instance
@@ -53,6 +62,10 @@ public:
     // Get list of available templates
     std::vector<std::string> list_templates() const;
 
+    // Lock hash of a template, or nullopt if it is unknown or not pinned
+    // (an unpinned template cannot reproduce any exact environment)
+    std::optional<std::string> lock_hash(const std::string& name) const;
+
     // Cleanup old/unused environments
     void cleanup_old_environments();
 
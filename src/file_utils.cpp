@@ -202,6 +202,94 @@ std::string FileUtils::get_mime_type(const std::string& filename) {
     return "application/octet-stream";
 }
 
+// "MZ" alone starts plenty of text files; a Windows image also has a PE
+// signature at the offset stored little-endian at 0x3C (e_lfanew)
+static bool is_pe_image(std::ifstream& file) {
+    unsigned char offset_bytes[4] = {};
+    file.clear();
+    file.seekg(0x3C);
+    if (!file.read(reinterpret_cast<char*>(offset_bytes), sizeof(offset_bytes))) {
+        return false;
+    }
+    uint32_t pe_offset = offset_bytes[0] | (offset_bytes[1] << 8) |
+                         (offset_bytes[2] << 16) | (static_cast<uint32_t>(offset_bytes[3]) << 24);
+
+    char signature[4] = {};
+    file.seekg(pe_offset);
+    if (!file.read(signature, sizeof(signature))) {
+        return false;
+    }
+    return std::string(signature, sizeof(signature)) == std::string("PE\0\0", 4);
+}
+
+std::string FileUtils::sniff_mime_type(const std::string& filepath) {
+    static const std::vector<std::pair<std::string, std::string>> signatures = {
+        {"\x7f" "ELF", "application/x-executable"},
+        {"\xfe\xed\xfa\xce", "application/x-mach-binary"},
+        {"\xfe\xed\xfa\xcf", "application/x-mach-binary"},
+        {"\xce\xfa\xed\xfe", "application/x-mach-binary"},
+        {"\xcf\xfa\xed\xfe", "application/x-mach-binary"},
+        {"#!", "text/x-shellscript"},
+        {"\x89PNG\r\n\x1a\n", "image/png"},
+        {"\xff\xd8\xff", "image/jpeg"},
+        {"GIF8", "image/gif"},
+        {"%PDF-", "application/pdf"},
+        {"PK\x03\x04", "application/zip"},
+        {"\x1f\x8b", "application/gzip"},
+    };
+
+    std::ifstream file(filepath, std::ios::binary);
+    char buffer[8] = {};
+    file.read(buffer, sizeof(buffer));
+    std::string head(buffer, static_cast<size_t>(file.gcount()));
+
+    if (head.compare(0, 2, "MZ") == 0 && is_pe_image(file)) {
+        return "application/x-msdownload";
+    }
+    for (const auto& [magic, mime_type] : signatures) {
+        if (head.compare(0, magic.size(), magic) == 0) {
+            return mime_type;
+        }
+    }
+    return get_mime_type(filepath);
+}
+
+bool ContentPolicy::permits(const std::string& mime_type) const {
+    auto matches = [&mime_type](const std::string& entry) {
+        if (entry.size() > 2 && entry.compare(entry.size() - 2, 2, "/*") == 0) {
+            return mime_type.compare(0, entry.size() - 1, entry, 0, entry.size() - 1) == 0;
+        }
+        return entry == mime_type;
+    };
+    if (!allowed.empty() && std::none_of(allowed.begin(), allowed.end(), matches)) {
+        return false;
+    }
+    return std::none_of(blocked.begin(), blocked.end(), matches);
+}
+
+std::optional<std::string> FileUtils::check_content_policy(
+    const std::string& root,
+    const std::map<std::string, FileMetadata>& files,
+    const ContentPolicy& policy,
+    std::vector<std::string>* forbidden) {
+    std::optional<std::string> error;
+    for (const auto& [path, metadata] : files) {
+        std::string mime_type = sniff_mime_type(root + "/" + path);
+        if (policy.permits(mime_type)) {
+            continue;
+        }
+        if (!error) {
+            error = "Output '" + path + "' has forbidden content type " + mime_type;
+        }
+        if (forbidden) {
+            forbidden->push_back(path);
+        } else {
+            break;
+        }
+    }
+    return error;
+}
+
 std::string FileUtils::format_file_size(size_t bytes) {
     const char* units[] = {"B", "KB", "MB", "GB", "TB"};
     int unit_index = 0;
@@ -298,6 +386,96 @@ std::string FileUtils::sha256_file(const std::string& filepath) {
     return bytes_to_hex(hash, SHA256_DIGEST_LENGTH);
 }
 
+std::string FileUtils::chunk_tree_root(const ChunkTree& tree) {
+    std::ostringstream data;
+    data << CHUNK_TREE_HASH_DOMAIN << ":"
+         << tree.chunk_size << ":" << tree.total_size << ":";
+    for (const auto& hash : tree.chunk_hashes) {
+        data << hash << "|";
+    }
+    return sha256_string(data.str());
+}
+
+ChunkTree FileUtils::chunk_hash_file(const std::string& filepath, size_t chunk_size) {
+    ChunkTree tree;
+    tree.chunk_size = chunk_size;
+
+    std::ifstream file(filepath, std::ios::binary);
+    if (!file.is_open() || chunk_size == 0) {
+        return tree;  // Empty tree (no root) on error
+    }
+
+    std::string chunk(chunk_size, '\0');
+    while (file.read(&chunk[0], chunk_size) || file.gcount() > 0) {
+        size_t n = static_cast<size_t>(file.gcount());
+        tree.chunk_hashes.push_back(sha256_string(chunk.substr(0, n)));
+        tree.total_size += n;
+    }
+
+    tree.root_hash = chunk_tree_root(tree);
+    return tree;
+}
+
+bool FileUtils::verify_stream(std::istream& in, const ChunkTree& tree,
+                              const std::string& expected_root, std::string* error) {
+    auto fail = [error](const std::string& reason) {
+        if (error) *error = reason;
+        return false;
+    };
+
+    // The tree must be the one the worker signed before its chunk hashes
+    // are trusted
+    if (tree.chunk_size == 0 || tree.root_hash != expected_root ||
+        chunk_tree_root(tree) != expected_root) {
+        return fail("chunk tree root mismatch");
+    }
+
+    std::string chunk(tree.chunk_size, '\0');
+    size_t index = 0;
+    uint64_t total = 0;
+    while (in.read(&chunk[0], tree.chunk_size) || in.gcount() > 0) {
+        size_t n = static_cast<size_t>(in.gcount());
+        if (index >= tree.chunk_hashes.size()) {
+            return fail("stream longer than chunk tree");
+        }
+        if (sha256_string(chunk.substr(0, n)) != tree.chunk_hashes[index]) {
+            return fail("chunk " + std::to_string(index) + " hash mismatch");
+        }
+        total += n;
+        index++;
+    }
+
+    if (index != tree.chunk_hashes.size()) {
+        return fail("stream ended after " + std::to_string(index) + " of " +
+                    std::to_string(tree.chunk_hashes.size()) + " chunks");
+    }
+    if (total != tree.total_size) {
+        return fail("stream size mismatch");
+    }
+    return true;
+}
+
+std::string FileUtils::outputs_hash(std::vector<std::pair<std::string, std::string>> outputs) {
+    std::sort(outputs.begin(), outputs.end());
+
+    // Names are length-prefixed so no choice of name can shift the
+    // boundary between one entry and the next
+    std::ostringstream data;
+    data << OUTPUT_HASH_DOMAIN << ":" << outputs.size() << ":";
+    for (const auto& [name, hash] : outputs) {
+        data << name.size() << ":" << name << "=" << hash << "|";
+    }
+    return sha256_string(data.str());
+}
+
+std::string FileUtils::outputs_hash(const std::map<std::string, FileMetadata>& files) {
+    std::vector<std::pair<std::string, std::string>> outputs;
+    for (const auto& [path, metadata] : files) {
+        outputs.emplace_back(path, metadata.sha256_hash);
+    }
+    return outputs_hash(std::move(outputs));
+}
+
 FileMetadata FileUtils::get_file_metadata(const std::string& filepath) {
     FileMetadata metadata;
     metadata.path = filepath;
@@ -359,6 +537,7 @@ std::map<std::string, FileMetadata> FileUtils::hash_directory(
         if (matches) {
             FileMetadata metadata = get_file_metadata(filepath);
             metadata.path = relpath;  // Store relative path
+            metadata.chunk_root = chunk_hash_file(filepath).root_hash;
             result[relpath] = metadata;
         }
     }
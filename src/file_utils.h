@@ -2,8 +2,13 @@
 
 #include <string>
 #include <filesystem>
+#include <istream>
+#include <fstream>
+#include <optional>
 #include <map>
 #include <vector>
+#include <cstdint>
+#include "constants.h"
 
 namespace sandrun {
 
@@ -27,6 +32,27 @@ struct FileMetadata {
     size_t size_bytes;
     std::string sha256_hash;
     FileType type;
+    std::string chunk_root;  // ChunkTree root, signed with the result (set by hash_directory)
+};
+
+// Per-chunk hashes of a file, so a verifier can check a large output as it
+// streams in and stop at the first bad chunk instead of after the download
+struct ChunkTree {
+    size_t chunk_size = 0;
+    uint64_t total_size = 0;
+    std::vector<std::string> chunk_hashes;  // sha256 of each chunk, in order
+    std::string root_hash;                  // Binds chunk_size, total_size and chunk_hashes
+};
+
+// Operator policy on the content types jobs may produce. A type is
+// permitted if it matches allowed (or allowed is empty) and matches nothing
+// in blocked. Entries are MIME types; "type/*" matches a whole family.
+struct ContentPolicy {
+    std::vector<std::string> allowed;
+    std::vector<std::string> blocked;
+
+    bool empty() const { return allowed.empty() && blocked.empty(); }
+    bool permits(const std::string& mime_type) const;
 };
 
 class FileUtils {
@@ -40,6 +66,20 @@ public:
     // Get MIME type for file
     static std::string get_mime_type(const std::string& filename);
 
+    // MIME type from a file's leading bytes (executables, archives, common
+    // images, PDF, scripts), falling back to get_mime_type on the name;
+    // renaming a binary doesn't change what it sniffs as
+    static std::string sniff_mime_type(const std::string& filepath);
+
+    // First output under root whose sniffed type the policy forbids, as an
+    // error message; nullopt if all are permitted. Every forbidden path is
+    // appended to forbidden when given
+    static std::optional<std::string> check_content_policy(
+        const std::string& root,
+        const std::map<std::string, FileMetadata>& files,
+        const ContentPolicy& policy,
+        std::vector<std::string>* forbidden = nullptr);
+
     // Format file size as human-readable string
     static std::string format_file_size(size_t bytes);
 
@@ -51,6 +91,25 @@ public:
     static std::string sha256_string(const std::string& data);
     static std::string bytes_to_hex(const unsigned char* data, size_t len);
 
+    // Streaming verification for large outputs
+    static ChunkTree chunk_hash_file(const std::string& filepath,
+                                     size_t chunk_size = OUTPUT_CHUNK_SIZE);
+    static std::string chunk_tree_root(const ChunkTree& tree);
+
+    // Check a stream chunk by chunk against a tree, reading at most one chunk
+    // into memory; fails at the first mismatched chunk. expected_root must
+    // come from signed data (FileMetadata::chunk_root in a signed result),
+    // since a tree can be rebuilt to match any content
+    static bool verify_stream(std::istream& in, const ChunkTree& tree,
+                              const std::string& expected_root,
+                              std::string* error = nullptr);
+
+    // Single digest over a set of (name, sha256) outputs. Outputs are
+    // sorted by name first, so the digest never depends on the order they
+    // were collected or stored in
+    static std::string outputs_hash(std::vector<std::pair<std::string, std::string>> outputs);
+    static std::string outputs_hash(const std::map<std::string, FileMetadata>& files);
+
     // Get file metadata with hash
     static FileMetadata get_file_metadata(const std::string& filepath);
 
@@ -14,6 +14,47 @@
 
 namespace sandrun {
 
+int status_code_for(ApiError error) {
+    switch (error) {
+        case ApiError::INVALID_REQUEST:   return 400;
+        case ApiError::UNAUTHORIZED:      return 401;
+        case ApiError::FORBIDDEN:         return 403;
+        case ApiError::NOT_FOUND:         return 404;
+        case ApiError::CONFLICT:          return 409;
+        case ApiError::PAYLOAD_TOO_LARGE: return 413;
+        case ApiError::VALIDATION_FAILED: return 422;
+        case ApiError::RATE_LIMITED:      return 429;
+        case ApiError::NO_CAPACITY:       return 503;
+        case ApiError::UNSUPPORTED:       return 501;
+        default:                          return 500;
+    }
+}
+
+int grpc_code_for(ApiError error) {
+    // Numeric google.rpc.Code values
+    switch (error) {
+        case ApiError::INVALID_REQUEST:   return 3;   // INVALID_ARGUMENT
+        case ApiError::UNAUTHORIZED:      return 16;  // UNAUTHENTICATED
+        case ApiError::FORBIDDEN:         return 7;   // PERMISSION_DENIED
+        case ApiError::NOT_FOUND:         return 5;   // NOT_FOUND
+        case ApiError::CONFLICT:          return 9;   // FAILED_PRECONDITION
+        case ApiError::PAYLOAD_TOO_LARGE: return 11;  // OUT_OF_RANGE
+        case ApiError::VALIDATION_FAILED: return 3;   // INVALID_ARGUMENT
+        case ApiError::RATE_LIMITED:      return 8;   // RESOURCE_EXHAUSTED
+        case ApiError::NO_CAPACITY:       return 14;  // UNAVAILABLE
+        case ApiError::UNSUPPORTED:       return 12;  // UNIMPLEMENTED
+        default:                          return 13;  // INTERNAL
+    }
+}
+
+HttpResponse error_response(ApiError error, const std::string& message) {
+    // message is inserted verbatim; callers escape anything untrusted
+    HttpResponse resp;
+    resp.status_code = status_code_for(error);
+    resp.body = "{\"error\":\"" + message + "\"}";
+    return resp;
+}
+
 HttpServer::HttpServer(int port) : port_(port), server_fd_(-1), running_(false) {}
 
 HttpServer::~HttpServer() {
@@ -283,8 +324,15 @@ std::string HttpServer::build_response(const HttpResponse& resp) {
     switch (resp.status_code) {
         case 200: out << "OK"; break;
         case 400: out << "Bad Request"; break;
+        case 401: out << "Unauthorized"; break;
+        case 403: out << "Forbidden"; break;
         case 404: out << "Not Found"; break;
+        case 409: out << "Conflict"; break;
+        case 413: out << "Payload Too Large"; break;
+        case 422: out << "Unprocessable Entity"; break;
+        case 429: out << "Too Many Requests"; break;
         case 500: out << "Internal Server Error"; break;
+        case 503: out << "Service Unavailable"; break;
         default: out << "Unknown"; break;
     }
     out << "\r\n";
@@ -29,6 +29,29 @@ struct HttpResponse {
     }
 };
 
+// Error categories for API responses. Every front-end maps these through
+// status_code_for()/grpc_code_for() so gateways agree on codes; a category
+// without an explicit mapping falls back to 500 / INTERNAL
+enum class ApiError {
+    INVALID_REQUEST,     // Malformed request (bad JSON, missing fields)
+    UNAUTHORIZED,        // Submitter not authenticated
+    FORBIDDEN,           // Authenticated but not allowed (e.g. path traversal)
+    NOT_FOUND,           // Unknown job or file
+    CONFLICT,            // Request conflicts with current state
+    PAYLOAD_TOO_LARGE,   // Request or upload over a size limit
+    VALIDATION_FAILED,   // Well-formed but semantically invalid manifest
+    RATE_LIMITED,        // Per-IP quota exhausted
+    NO_CAPACITY,         // Temporarily unable to accept work
+    UNSUPPORTED,         // Valid request for a feature this server doesn't provide
+    INTERNAL             // Unexpected failure
+};
+
+int status_code_for(ApiError error);
+int grpc_code_for(ApiError error);
+
+// Build a JSON error response ({"error":"..."}) with the mapped status
+HttpResponse error_response(ApiError error, const std::string& message);
+
 // Request handler function type
 using HandlerFunc = std::function<HttpResponse(const HttpRequest&)>;
 
@@ -1,19 +1,119 @@
 #include "job_hash.h"
 #include "file_utils.h"
+#include "constants.h"
 #include <sstream>
+#include <algorithm>
+#include <cctype>
 
 namespace sandrun {
 
+// Length-prefixed field: "<len>:<value>|", so no value can spill into
+// its neighbour whatever characters it contains
+static void put_field(std::ostringstream& ss, const std::string& value) {
+    ss << value.size() << ":" << value << "|";
+}
+
 std::string JobDefinition::calculate_hash() const {
     std::ostringstream job_data;
-    job_data << entrypoint << "|"
-             << interpreter << "|"
-             << environment << "|";
+    job_data << JOB_HASH_DOMAIN << ":";
+    put_field(job_data, entrypoint);
+    put_field(job_data, interpreter);
+    put_field(job_data, environment);
+    job_data << args.size() << ":";
     for (const auto& arg : args) {
-        job_data << arg << "|";
+        put_field(job_data, arg);
+    }
+
+    // Egress allowlist is order- and case-insensitive
+    std::vector<std::string> hosts;
+    for (const auto& host : allowed_egress) {
+        std::string lowered = host;
+        std::transform(lowered.begin(), lowered.end(), lowered.begin(),
+                       [](unsigned char c) { return std::tolower(c); });
+        hosts.push_back(lowered);
+    }
+    std::sort(hosts.begin(), hosts.end());
+    hosts.erase(std::unique(hosts.begin(), hosts.end()), hosts.end());
+    job_data << hosts.size() << ":";
+    for (const auto& host : hosts) {
+        put_field(job_data, host);
     }
-    job_data << code;
+
+    // An explicit seed changes what the job computes; a derived one is a
+    // function of the hash already, so it is not hashed again
+    put_field(job_data, random_seed ? std::to_string(*random_seed) : "");
+    put_field(job_data, environment_lock);
+    put_field(job_data, code);
     return FileUtils::sha256_string(job_data.str());
 }
 
+std::optional<std::vector<std::string>> normalize_args(
+    const std::vector<std::string>& args,
+    const std::string& interpreter,
+    std::string* error) {
+    static const std::vector<std::string> known = {"python3", "python", "node", "bash", "sh"};
+    if (std::find(known.begin(), known.end(), interpreter) == known.end()) {
+        if (error) *error = "No argument convention for interpreter: " + interpreter;
+        return std::nullopt;
+    }
+
+    std::vector<std::string> normalized;
+    bool positional = false;
+    for (const auto& arg : args) {
+        if (positional) {
+            normalized.push_back(arg);
+            continue;
+        }
+        if (arg == "--") {
+            positional = true;
+            normalized.push_back(arg);
+            continue;
+        }
+
+        size_t eq = arg.find('=');
+        if (arg.size() > 2 && arg.compare(0, 2, "--") == 0 && eq != std::string::npos && eq > 2) {
+            normalized.push_back(arg.substr(0, eq));
+            normalized.push_back(arg.substr(eq + 1));
+        } else {
+            normalized.push_back(arg);
+        }
+    }
+    return normalized;
+}
+
+std::string JobDefinition::cache_key() const {
+    if (cache_exclude_args.empty()) {
+        return calculate_hash();
+    }
+
+    auto excluded = [this](const std::string& flag) {
+        return std::find(cache_exclude_args.begin(), cache_exclude_args.end(), flag) !=
+               cache_exclude_args.end();
+    };
+
+    JobDefinition stripped = *this;
+    stripped.args.clear();
+    bool positional = false;
+    for (const auto& arg : args) {
+        if (!positional && arg == "--") {
+            positional = true;
+        } else if (!positional) {
+            size_t eq = arg.find('=');
+            if (excluded(eq == std::string::npos ? arg : arg.substr(0, eq))) {
+                continue;
+            }
+        }
+        stripped.args.push_back(arg);
+    }
+    return stripped.calculate_hash();
+}
+
+uint64_t JobDefinition::derive_seed() const {
+    if (random_seed) {
+        return *random_seed;
+    }
+    // 16 hex chars = 64 bits; drop the top bit so the seed fits a signed int64
+    return std::stoull(calculate_hash().substr(0, 16), nullptr, 16) >> 1;
+}
+
 } // namespace sandrun
@@ -1,6 +1,8 @@
 #pragma once
 #include <string>
 #include <vector>
+#include <optional>
+#include <cstdint>
 
 namespace sandrun {
 
@@ -11,10 +13,40 @@ struct JobDefinition {
     std::string environment;
     std::vector<std::string> args;
     std::string code;  // entrypoint content
+    std::vector<std::string> allowed_egress = {};  // Hosts the job may connect to
+    std::optional<uint64_t> random_seed = std::nullopt;  // Explicit seed from the manifest
+    std::string environment_lock = "";    // Required EnvironmentTemplate::lock_hash (optional)
+    std::vector<std::string> cache_exclude_args = {};  // Flags irrelevant to results
 
     // Calculate deterministic job hash from all job parameters
     // This hash uniquely identifies the job specification
     std::string calculate_hash() const;
+
+    // Key for caching results: the hash of this job with every argument
+    // that is exactly a flag in cache_exclude_args, or that flag with
+    // "=value", removed from args, so jobs that differ only in incidental
+    // arguments (request IDs, log timestamps) share results. A value passed
+    // as a separate argument is kept, since it can't be told from a
+    // positional one; arguments after a bare "--" are kept too. Equal to
+    // calculate_hash() when nothing is excluded. The job hash itself still
+    // covers every argument.
+    std::string cache_key() const;
+
+    // Seed every node uses for this job's randomness: random_seed if set,
+    // otherwise the top 63 bits of the job hash, so identical jobs get
+    // identical "random" behaviour wherever they run
+    uint64_t derive_seed() const;
 };
 
+// Canonicalize equivalent argument spellings so equivalent invocations get
+// the same job hash. Opt-in, since some programs are format sensitive.
+// For python3, python, node, bash and sh:
+//   - "--name=value" becomes "--name", "value"
+//   - arguments after a bare "--" are left untouched
+// Nothing else is rewritten. Unknown interpreters return nullopt with error set.
+std::optional<std::vector<std::string>> normalize_args(
+    const std::vector<std::string>& args,
+    const std::string& interpreter,
+    std::string* error = nullptr);
+
 } // namespace sandrun
@@ -11,7 +11,10 @@
 #include "websocket.h"
 #include "file_utils.h"
 #include "environment_manager.h"
+#include "acceptance.h"
 #include "worker_identity.h"
+#include "job_hash.h"
+#include "proof.h"
 #include <iostream>
 #include <thread>
 #include <sstream>
@@ -22,8 +25,10 @@
 #include <queue>
 #include <mutex>
 #include <memory>
+#include <optional>
 #include <filesystem>
 #include <cstring>
+#include <cctype>
 #include <sys/socket.h>
 
 using namespace sandrun;
@@ -38,20 +43,30 @@ struct Job {
     std::vector<std::string> args;
     std::vector<std::string> outputs;
     std::string environment;               // Environment template name (optional)
+    std::string environment_lock;          // Required template lock hash (optional)
+    std::vector<std::string> allowed_egress;  // Requested hosts; rejected unless empty
+    std::optional<uint64_t> random_seed;   // Explicit seed from the manifest
+    bool normalize_args = false;           // Canonicalize args before hashing (opt-in)
+    std::vector<std::string> cache_exclude_args;  // Flags left out of the cache key
+    uint64_t seed = 0;                     // Seed the job runs with (explicit or derived)
     std::string status = "queued";
     std::string stdout_log;
     std::string stderr_log;
     std::string working_dir;
     int queue_position = 0;
+    int timeout_seconds = DEFAULT_TIMEOUT_SECONDS;  // Limit JobExecutor enforces on the run
     double cpu_seconds = 0;
     size_t memory_mb = 0;
     std::chrono::steady_clock::time_point created_at;
 
     // Verification metadata (for trustless pools)
     std::string job_hash;                  // SHA256 of job inputs (commitment)
+    std::string cache_key;                 // job_hash minus cache_exclude_args
     std::map<std::string, FileMetadata> output_files;  // Output file metadata with hashes
     int64_t wall_time_ms = 0;              // Wall clock time in milliseconds
     int exit_code = 0;                     // Process exit code
+    std::string policy_error;              // Why the output content policy failed the job
+    FailureReason failure_reason = FailureReason::NONE;  // How the job process ended
 
     // Worker identity (for signed results)
     std::string worker_id;                 // Worker public key (base64)
@@ -144,6 +159,38 @@ std::vector<std::string> json_get_string_array(const std::string& json, const st
     return result;
 }
 
+// Parse a non-negative JSON integer
+std::optional<uint64_t> json_get_uint64(const std::string& json, const std::string& key) {
+    size_t key_pos = json.find("\"" + key + "\"");
+    if (key_pos == std::string::npos) return std::nullopt;
+
+    size_t colon = json.find(':', key_pos);
+    if (colon == std::string::npos) return std::nullopt;
+
+    size_t value_start = json.find_first_not_of(" \t\r\n", colon + 1);
+    if (value_start == std::string::npos || !std::isdigit(static_cast<unsigned char>(json[value_start]))) {
+        return std::nullopt;
+    }
+
+    try {
+        return std::stoull(json.substr(value_start));
+    } catch (const std::exception&) {
+        return std::nullopt;  // Out of range
+    }
+}
+
+// Parse a JSON boolean (false if absent)
+bool json_get_bool(const std::string& json, const std::string& key) {
+    size_t key_pos = json.find("\"" + key + "\"");
+    if (key_pos == std::string::npos) return false;
+
+    size_t colon = json.find(':', key_pos);
+    if (colon == std::string::npos) return false;
+
+    size_t value_start = json.find_first_not_of(" \t\r\n", colon + 1);
+    return value_start != std::string::npos && json.compare(value_start, 4, "true") == 0;
+}
+
 // Generate unique job ID
 std::string generate_job_id() {
     static int counter = 0;
@@ -214,6 +261,8 @@ int main(int argc, char* argv[]) {
     int port = 8443;
     std::string worker_key_file;
     bool generate_key = false;
+    size_t max_output_patterns = MAX_OUTPUT_PATTERNS;
+    ContentPolicy output_policy;
 
     // Parse command line
     for (int i = 1; i < argc; i++) {
@@ -223,6 +272,12 @@ int main(int argc, char* argv[]) {
             worker_key_file = argv[++i];
         } else if (std::string(argv[i]) == "--generate-key") {
             generate_key = true;
+        } else if (std::string(argv[i]) == "--max-outputs" && i + 1 < argc) {
+            max_output_patterns = std::strtoull(argv[++i], nullptr, 10);
+        } else if (std::string(argv[i]) == "--allow-output-type" && i + 1 < argc) {
+            output_policy.allowed.push_back(argv[++i]);
+        } else if (std::string(argv[i]) == "--block-output-type" && i + 1 < argc) {
+            output_policy.blocked.push_back(argv[++i]);
         }
     }
 
@@ -277,13 +332,13 @@ int main(int argc, char* argv[]) {
     HttpServer server(port);
     
     // POST /submit - Submit job with files and manifest
-    server.route("POST", "/submit", [&rate_limiter](const HttpRequest& req) {
+    server.route("POST", "/submit", [&rate_limiter, &worker_identity, max_output_patterns](const HttpRequest& req) {
         HttpResponse resp;
         
         // Check rate limit
         auto quota = rate_limiter.check_quota(req.client_ip);
         if (!quota.can_submit) {
-            resp.status_code = 429;  // Too Many Requests
+            resp.status_code = status_code_for(ApiError::RATE_LIMITED);
             std::stringstream json;
             json << "{\"error\":\"" << quota.reason << "\","
                  << "\"cpu_available\":" << quota.cpu_seconds_available << ","
@@ -299,8 +354,7 @@ int main(int argc, char* argv[]) {
         );
         
         if (parts.empty()) {
-            resp.status_code = 400;
-            resp.body = "{\"error\":\"No files uploaded\"}";
+            resp = error_response(ApiError::INVALID_REQUEST, "No files uploaded");
             return resp;
         }
         
@@ -324,12 +378,22 @@ int main(int argc, char* argv[]) {
 
                 // Parse environment template
                 job->environment = json_get_string(manifest, "environment");
+                job->environment_lock = json_get_string(manifest, "environment_lock");
 
                 // Parse output patterns
                 job->outputs = json_get_string_array(manifest, "outputs");
 
                 // Parse args
                 job->args = json_get_string_array(manifest, "args");
+
+                // Parse egress allowlist
+                job->allowed_egress = json_get_string_array(manifest, "allowed_egress");
+
+                // Parse explicit random seed
+                job->random_seed = json_get_uint64(manifest, "random_seed");
+
+                job->normalize_args = json_get_bool(manifest, "normalize_args");
+                job->cache_exclude_args = json_get_string_array(manifest, "cache_exclude_args");
             }
         }
         
@@ -348,6 +412,9 @@ int main(int argc, char* argv[]) {
                 if (job->environment.empty()) {
                     job->environment = json_get_string(manifest, "environment");
                 }
+                if (job->environment_lock.empty()) {
+                    job->environment_lock = json_get_string(manifest, "environment_lock");
+                }
 
                 // Parse output patterns and args from file manifest
                 if (job->outputs.empty()) {
@@ -356,6 +423,18 @@ int main(int argc, char* argv[]) {
                 if (job->args.empty()) {
                     job->args = json_get_string_array(manifest, "args");
                 }
+                if (job->allowed_egress.empty()) {
+                    job->allowed_egress = json_get_string_array(manifest, "allowed_egress");
+                }
+                if (!job->random_seed) {
+                    job->random_seed = json_get_uint64(manifest, "random_seed");
+                }
+                if (!job->normalize_args) {
+                    job->normalize_args = json_get_bool(manifest, "normalize_args");
+                }
+                if (job->cache_exclude_args.empty()) {
+                    job->cache_exclude_args = json_get_string_array(manifest, "cache_exclude_args");
+                }
             }
         }
         
@@ -374,31 +453,76 @@ int main(int argc, char* argv[]) {
         }
         
         if (job->entrypoint.empty()) {
-            resp.status_code = 400;
-            resp.body = "{\"error\":\"No entrypoint specified\"}";
+            resp = error_response(ApiError::INVALID_REQUEST, "No entrypoint specified");
+            fs::remove_all(job->working_dir);
+            return resp;
+        }
+
+        // Jobs have no network route, so a job asking for egress can't get it;
+        // refuse it rather than hash a policy that was never applied
+        if (!job->allowed_egress.empty()) {
+            resp = error_response(ApiError::UNSUPPORTED,
+                "allowed_egress is not supported: jobs run without network access");
+            fs::remove_all(job->working_dir);
+            return resp;
+        }
+
+        // Bound manifest complexity before output hashing has to walk it
+        if (job->outputs.size() > max_output_patterns) {
+            resp = error_response(ApiError::VALIDATION_FAILED,
+                "Too many output patterns: " + std::to_string(job->outputs.size()) +
+                " (max " + std::to_string(max_output_patterns) + ")");
             fs::remove_all(job->working_dir);
             return resp;
         }
 
+        // Canonicalize args so equivalent spellings hash (and run) the same
+        if (job->normalize_args) {
+            std::string error;
+            auto normalized = normalize_args(job->args, job->interpreter, &error);
+            if (!normalized) {
+                resp = error_response(ApiError::VALIDATION_FAILED, json_escape(error));
+                fs::remove_all(job->working_dir);
+                return resp;
+            }
+            job->args = *normalized;
+        }
+
+        // A locked job only runs if this node can build exactly that environment
+        if (!job->environment_lock.empty()) {
+            auto available = EnvironmentManager::instance().lock_hash(job->environment);
+            if (!available || *available != job->environment_lock) {
+                resp = error_response(ApiError::VALIDATION_FAILED,
+                    "Cannot reproduce environment lock " + json_escape(job->environment_lock) +
+                    " for environment '" + json_escape(job->environment) + "'");
+                fs::remove_all(job->working_dir);
+                return resp;
+            }
+        }
+
         // Calculate job hash (commitment to job inputs for verification)
         {
-            std::ostringstream job_data;
-            job_data << job->entrypoint << "|"
-                     << job->interpreter << "|"
-                     << job->environment << "|";
-            for (const auto& arg : job->args) {
-                job_data << arg << "|";
-            }
+            JobDefinition definition;
+            definition.entrypoint = job->entrypoint;
+            definition.interpreter = job->interpreter;
+            definition.environment = job->environment;
+            definition.args = job->args;
+            definition.allowed_egress = job->allowed_egress;
+            definition.random_seed = job->random_seed;
+            definition.environment_lock = job->environment_lock;
+            definition.cache_exclude_args = job->cache_exclude_args;
 
             // Include entrypoint file content in hash
             std::string entrypoint_path = job->working_dir + "/" + job->entrypoint;
             if (fs::exists(entrypoint_path)) {
                 std::ifstream ent_file(entrypoint_path);
-                job_data << std::string((std::istreambuf_iterator<char>(ent_file)),
-                                        std::istreambuf_iterator<char>());
+                definition.code = std::string((std::istreambuf_iterator<char>(ent_file)),
+                                              std::istreambuf_iterator<char>());
             }
 
-            job->job_hash = FileUtils::sha256_string(job_data.str());
+            job->job_hash = definition.calculate_hash();
+            job->cache_key = definition.cache_key();
+            job->seed = definition.derive_seed();
         }
 
         // Add to queue
@@ -407,16 +531,19 @@ int main(int argc, char* argv[]) {
         
         // Register with rate limiter
         if (!rate_limiter.register_job_start(client_ip, job_id)) {
-            resp.status_code = 429;
-            resp.body = "{\"error\":\"Rate limit exceeded\"}";
+            resp = error_response(ApiError::RATE_LIMITED, "Rate limit exceeded");
             fs::remove_all(job->working_dir);
             return resp;
         }
         
+        std::string job_hash = job->job_hash;
+        int timeout_seconds = job->timeout_seconds;
+        int queue_position;
         {
             std::lock_guard<std::mutex> lock(jobs_mutex);
             job_queue.push(job_id);
             job->queue_position = job_queue.size();
+            queue_position = job->queue_position;
             jobs[job_id] = std::move(job);
         }
         
@@ -424,7 +551,20 @@ int main(int argc, char* argv[]) {
                   << " from IP: " << client_ip
                   << " (entrypoint: " << jobs[job_id]->entrypoint << ")" << std::endl;
         
-        resp.body = "{\"job_id\":\"" + job_id + "\",\"status\":\"queued\"}";
+        resp.body = "{\"job_id\":\"" + job_id + "\",\"status\":\"queued\"";
+
+        // Commit to a deadline: jobs run one at a time, each bounded by the
+        // timeout, behind the queue and whichever job is running now
+        if (worker_identity) {
+            auto now = std::chrono::system_clock::now().time_since_epoch();
+            int64_t deadline = JobAcceptance::deadline_for(
+                std::chrono::duration_cast<std::chrono::seconds>(now).count(),
+                queue_position, timeout_seconds);
+            auto acceptance = JobAcceptance::build(job_id, job_hash, deadline, *worker_identity);
+            resp.body += ",\"acceptance\":" + acceptance.to_json();
+        }
+
+        resp.body += "}";
         return resp;
     });
     
@@ -437,8 +577,7 @@ int main(int argc, char* argv[]) {
         std::lock_guard<std::mutex> lock(jobs_mutex);
         auto it = jobs.find(job_id);
         if (it == jobs.end()) {
-            resp.status_code = 404;
-            resp.body = "{\"error\":\"Job not found\"}";
+            resp = error_response(ApiError::NOT_FOUND, "Job not found");
             return resp;
         }
 
@@ -450,6 +589,9 @@ int main(int argc, char* argv[]) {
         json << "{\n";
         json << "  \"job_id\": \"" << job_id << "\",\n";
         json << "  \"status\": \"" << job->status << "\",\n";
+        if (!job->policy_error.empty()) {
+            json << "  \"error\": \"" << json_escape(job->policy_error) << "\",\n";
+        }
         json << "  \"queue_position\": " << job->queue_position << ",\n";
 
         // Execution metadata
@@ -458,13 +600,25 @@ int main(int argc, char* argv[]) {
         json << "    \"memory_peak_bytes\": " << (job->memory_mb * 1024 * 1024) << ",\n";
         json << "    \"memory_peak_mb\": " << job->memory_mb << ",\n";
         json << "    \"wall_time_ms\": " << job->wall_time_ms << ",\n";
+        ProofOfCompute usage;
+        usage.cpu_time = job->cpu_seconds;
+        usage.gpu_time = 0;  // GPU time isn't metered yet
+        usage.memory_peak = job->memory_mb * 1024 * 1024;
+        json << "    \"work_units\": " << usage.work_units() << ",\n";
         json << "    \"exit_code\": " << job->exit_code << ",\n";
+        json << "    \"failure_reason\": \"" << failure_reason_to_string(job->failure_reason) << "\",\n";
+        json << "    \"retryable\": " << (is_retryable(job->failure_reason) ? "true" : "false") << ",\n";
         json << "    \"environment\": \"" << json_escape(job->environment) << "\",\n";
-        json << "    \"interpreter\": \"" << json_escape(job->interpreter) << "\"\n";
+        if (!job->environment_lock.empty()) {
+            json << "    \"environment_lock\": \"" << json_escape(job->environment_lock) << "\",\n";
+        }
+        json << "    \"interpreter\": \"" << json_escape(job->interpreter) << "\",\n";
+        json << "    \"random_seed\": " << job->seed << "\n";
         json << "  },\n";
 
         // Job commitment (verification hash)
         json << "  \"job_hash\": \"" << job->job_hash << "\",\n";
+        json << "  \"cache_key\": \"" << job->cache_key << "\",\n";
 
         // Output files with hashes (for verification)
         json << "  \"output_files\": {\n";
@@ -475,10 +629,12 @@ int main(int argc, char* argv[]) {
             json << "    \"" << json_escape(path) << "\": {\n";
             json << "      \"size_bytes\": " << metadata.size_bytes << ",\n";
             json << "      \"sha256\": \"" << metadata.sha256_hash << "\",\n";
+            json << "      \"chunk_root\": \"" << metadata.chunk_root << "\",\n";
             json << "      \"type\": \"" << FileUtils::file_type_to_string(metadata.type) << "\"\n";
             json << "    }";
         }
         json << "\n  },\n";
+        json << "  \"outputs_hash\": \"" << FileUtils::outputs_hash(job->output_files) << "\",\n";
 
         // Worker identity (for signed results in pools)
         json << "  \"worker_metadata\": {\n";
@@ -500,8 +656,7 @@ int main(int argc, char* argv[]) {
         std::lock_guard<std::mutex> lock(jobs_mutex);
         auto it = jobs.find(job_id);
         if (it == jobs.end()) {
-            resp.status_code = 404;
-            resp.body = "{\"error\":\"Job not found\"}";
+            resp = error_response(ApiError::NOT_FOUND, "Job not found");
             return resp;
         }
 
@@ -519,8 +674,7 @@ int main(int argc, char* argv[]) {
         std::lock_guard<std::mutex> lock(jobs_mutex);
         auto it = jobs.find(job_id);
         if (it == jobs.end()) {
-            resp.status_code = 404;
-            resp.body = "{\"error\":\"Job not found\"}";
+            resp = error_response(ApiError::NOT_FOUND, "Job not found");
             return resp;
         }
 
@@ -583,6 +737,49 @@ int main(int argc, char* argv[]) {
         return resp;
     });
     
+    // GET /chunks/{job_id}/{file_path} - per-chunk hashes of one output
+    server.route("GET", "/chunks/", [&](const HttpRequest& req) {
+        HttpResponse resp;
+
+        std::string rest = req.path.substr(8);  // After "/chunks/"
+        size_t slash_pos = rest.find('/');
+        if (slash_pos == std::string::npos) {
+            resp = error_response(ApiError::INVALID_REQUEST, "Expected /chunks/{job_id}/{file_path}");
+            return resp;
+        }
+        std::string job_id = rest.substr(0, slash_pos);
+        std::string file_path = rest.substr(slash_pos + 1);
+
+        std::lock_guard<std::mutex> lock(jobs_mutex);
+        auto it = jobs.find(job_id);
+        if (it == jobs.end()) {
+            resp = error_response(ApiError::NOT_FOUND, "Job not found");
+            return resp;
+        }
+
+        // Only hashed outputs have a signed root to check the tree against
+        auto file_it = it->second->output_files.find(file_path);
+        if (file_it == it->second->output_files.end()) {
+            resp = error_response(ApiError::NOT_FOUND, "File not found");
+            return resp;
+        }
+
+        ChunkTree tree = FileUtils::chunk_hash_file(it->second->working_dir + "/" + file_path);
+        std::stringstream json;
+        json << "{\n  \"chunk_size\": " << tree.chunk_size << ",\n";
+        json << "  \"total_size\": " << tree.total_size << ",\n";
+        json << "  \"root_hash\": \"" << tree.root_hash << "\",\n";
+        json << "  \"chunk_hashes\": [";
+        for (size_t i = 0; i < tree.chunk_hashes.size(); i++) {
+            if (i > 0) json << ", ";
+            json << "\"" << tree.chunk_hashes[i] << "\"";
+        }
+        json << "]\n}";
+
+        resp.body = json.str();
+        return resp;
+    });
+
     // GET /download/{job_id} or /download/{job_id}/{file_path}
     server.route("GET", "/download/", [&](const HttpRequest& req) {
         HttpResponse resp;
@@ -605,8 +802,7 @@ int main(int argc, char* argv[]) {
         std::lock_guard<std::mutex> lock(jobs_mutex);
         auto it = jobs.find(job_id);
         if (it == jobs.end()) {
-            resp.status_code = 404;
-            resp.body = "{\"error\":\"Job not found\"}";
+            resp = error_response(ApiError::NOT_FOUND, "Job not found");
             return resp;
         }
 
@@ -641,14 +837,12 @@ int main(int argc, char* argv[]) {
             std::string canonical_file = fs::canonical(full_path).string();
 
             if (canonical_file.find(canonical_work_dir) != 0) {
-                resp.status_code = 403;
-                resp.body = "{\"error\":\"Access denied: path traversal detected\"}";
+                resp = error_response(ApiError::FORBIDDEN, "Access denied: path traversal detected");
                 return resp;
             }
 
             if (!fs::exists(full_path) || !fs::is_regular_file(full_path)) {
-                resp.status_code = 404;
-                resp.body = "{\"error\":\"File not found\"}";
+                resp = error_response(ApiError::NOT_FOUND, "File not found");
                 return resp;
             }
 
@@ -737,6 +931,18 @@ int main(int argc, char* argv[]) {
         }
 
         json << "\n  ],\n";
+
+        // Lock hashes this node can satisfy (pinned templates only)
+        json << "  \"lock_hashes\": {";
+        bool first_lock = true;
+        for (const auto& name : templates) {
+            auto lock = env_mgr.lock_hash(name);
+            if (!lock) continue;
+            json << (first_lock ? "\n" : ",\n")
+                 << "    \"" << name << "\": \"" << *lock << "\"";
+            first_lock = false;
+        }
+        json << (first_lock ? "},\n" : "\n  },\n");
         json << "  \"stats\": {\n";
         json << "    \"total_templates\": " << stats.total_templates << ",\n";
         json << "    \"cached_environments\": " << stats.cached_environments << ",\n";
@@ -877,6 +1083,9 @@ int main(int argc, char* argv[]) {
 
                     job->status = (result.exit_code == 0) ? "completed" : "failed";
                     job->exit_code = result.exit_code;
+                    // JobExecutor reports no terminating signal, only the exit
+                    // status, so a signal death is decoded from that
+                    job->failure_reason = classify_exit_status(result.exit_code);
 
                     // Hash output files (for verification in trustless pools)
                     if (!job->outputs.empty()) {
@@ -886,6 +1095,28 @@ int main(int argc, char* argv[]) {
                         job->output_files = FileUtils::hash_directory(job->working_dir);
                     }
 
+                    // Enforce the operator's output content policy; forbidden
+                    // files are deleted so they can never be downloaded. Every
+                    // file in the working directory is checked, not just the
+                    // declared outputs, since /download serves all of them
+                    if (!output_policy.empty()) {
+                        std::vector<std::string> forbidden;
+                        auto violation = FileUtils::check_content_policy(
+                            job->working_dir, FileUtils::hash_directory(job->working_dir),
+                            output_policy, &forbidden);
+                        if (violation) {
+                            for (const auto& path : forbidden) {
+                                fs::remove(job->working_dir + "/" + path);
+                                job->output_files.erase(path);
+                            }
+                            job->status = "failed";
+                            job->failure_reason = FailureReason::CONTENT_POLICY;
+                            job->policy_error = *violation;
+                            job->stderr_log += "[POLICY] " + *violation + "\n";
+                            broadcaster.broadcast(next_job_id, "[POLICY] " + *violation + "\n");
+                        }
+                    }
+
                     // Sign result if worker has identity
                     if (worker_identity) {
                         job->worker_id = worker_identity->get_worker_id();
@@ -897,9 +1128,11 @@ int main(int argc, char* argv[]) {
                                   << job->cpu_seconds << "|"
                                   << job->memory_mb << "|";
 
-                        // Include output file hashes in signature
+                        // Include output file hashes in signature; the chunk root
+                        // lets a verifier check a download as it streams in
                         for (const auto& [path, metadata] : job->output_files) {
-                            sign_data << path << ":" << metadata.sha256_hash << "|";
+                            sign_data << path << ":" << metadata.sha256_hash << ":"
+                                      << metadata.chunk_root << "|";
                         }
 
                         job->result_signature = worker_identity->sign(sign_data.str());
@@ -1,9 +1,11 @@
 #include "proof.h"
+#include "constants.h"
 #include <openssl/sha.h>
 #include <sstream>
 #include <iomanip>
 #include <ctime>
 #include <memory>
+#include <algorithm>
 
 namespace sandrun {
 
@@ -22,6 +24,27 @@ static std::string sha256(const std::string& data) {
     return ss.str();
 }
 
+// Escape a string for embedding in JSON output
+static std::string json_escape(const std::string& str) {
+    std::stringstream ss;
+    for (unsigned char c : str) {
+        switch (c) {
+            case '"':  ss << "\\\""; break;
+            case '\\': ss << "\\\\"; break;
+            case '\n': ss << "\\n"; break;
+            case '\r': ss << "\\r"; break;
+            case '\t': ss << "\\t"; break;
+            default:
+                if (c < 0x20) {
+                    ss << "\\u" << std::hex << std::setw(4) << std::setfill('0') << (int)c << std::dec;
+                } else {
+                    ss << c;
+                }
+        }
+    }
+    return ss.str();
+}
+
 // ExecutionTrace implementation
 void ExecutionTrace::record_syscall(int syscall_num, uint64_t arg1, uint64_t arg2) {
     Syscall sc;
@@ -39,6 +62,7 @@ void ExecutionTrace::record_file_op(const std::string& op, const std::string& pa
 std::string ExecutionTrace::create_checkpoint() {
     // Create hash of current state
     std::stringstream ss;
+    ss << CHECKPOINT_HASH_DOMAIN << ":";
     ss << "syscalls:" << syscalls.size();
     ss << ",files:" << file_operations.size();
     
@@ -59,31 +83,106 @@ void ExecutionTrace::clear() {
     checkpoints.clear();
 }
 
-// ProofOfCompute implementation
-std::string ProofOfCompute::calculate_hash() const {
+// ExecutionDescriptor implementation
+std::string ExecutionDescriptor::hash_env(const std::map<std::string, std::string>& env) {
+    // std::map iterates in key order; lengths keep entries unambiguous
     std::stringstream ss;
-    ss << job_id;
-    ss << code_hash;
-    ss << input_hash;
-    ss << output_hash;
-    ss << execution_hash;
-    
-    for (const auto& checkpoint : checkpoint_hashes) {
-        ss << checkpoint;
+    ss << DESCRIPTOR_HASH_DOMAIN << ":env:" << env.size() << ":";
+    for (const auto& [key, value] : env) {
+        ss << key.size() << ":" << key << "=" << value.size() << ":" << value << "|";
     }
-    
-    ss << cpu_time;
-    ss << gpu_time;
-    ss << memory_peak;
-    ss << syscall_count;
-    
     return sha256(ss.str());
 }
 
+std::string ExecutionDescriptor::calculate_hash() const {
+    std::stringstream ss;
+    ss << DESCRIPTOR_HASH_DOMAIN << ":" << argv.size() << ":";
+    for (const auto& arg : argv) {
+        ss << arg.size() << ":" << arg << "|";
+    }
+    ss << work_dir.size() << ":" << work_dir << "|" << env_hash;
+    return sha256(ss.str());
+}
+
+// Length-prefixed field for canonical encodings: "<len>:<value>|"
+static void put_field(std::stringstream& ss, const std::string& value) {
+    ss << value.size() << ":" << value << "|";
+}
+
+// ProofOfCompute implementation
+std::optional<std::string> ProofOfCompute::canonical_bytes(std::string* error) const {
+    std::stringstream ss;
+    switch (proof_version) {
+        case 1: {
+            // Baseline encoding, byte for byte, so existing v1 proofs verify
+            if (descriptor) {
+                if (error) *error = "Execution descriptors need proof version 2 or later";
+                return std::nullopt;
+            }
+            ss << job_id;
+            ss << code_hash;
+            ss << input_hash;
+            ss << output_hash;
+            ss << execution_hash;
+
+            for (const auto& checkpoint : checkpoint_hashes) {
+                ss << checkpoint;
+            }
+
+            ss << cpu_time;
+            ss << gpu_time;
+            ss << memory_peak;
+            ss << syscall_count;
+            return ss.str();
+        }
+
+        case 2: {
+            ss << PROOF_V2_HASH_DOMAIN << ":";
+            put_field(ss, job_id);
+            put_field(ss, code_hash);
+            put_field(ss, input_hash);
+            put_field(ss, output_hash);
+            put_field(ss, execution_hash);
+            ss << checkpoint_hashes.size() << ":";
+            for (const auto& checkpoint : checkpoint_hashes) {
+                put_field(ss, checkpoint);
+            }
+
+            // hexfloat round-trips exactly; v1's default 6 digits did not
+            ss << std::hexfloat << cpu_time << "|" << gpu_time << "|" << std::defaultfloat
+               << memory_peak << "|" << syscall_count << "|";
+            put_field(ss, descriptor ? descriptor->calculate_hash() : "");
+            return ss.str();
+        }
+
+        default:
+            if (error) {
+                *error = "Unsupported proof version " + std::to_string(proof_version) +
+                         " (supported: " + std::to_string(MIN_PROOF_VERSION) + "-" +
+                         std::to_string(PROOF_VERSION) + ")";
+            }
+            return std::nullopt;
+    }
+}
+
+std::string ProofOfCompute::calculate_hash() const {
+    auto bytes = canonical_bytes();
+    return bytes ? sha256(*bytes) : "";
+}
+
+double ProofOfCompute::work_units() const {
+    double seconds = std::max(cpu_time, gpu_time);
+    double gib = static_cast<double>(memory_peak) / (1024.0 * 1024.0 * 1024.0);
+    return cpu_time
+         + GPU_SECOND_WORK_UNITS * gpu_time
+         + GIB_SECOND_WORK_UNITS * gib * seconds;
+}
+
 std::string ProofOfCompute::to_json() const {
     // Simple JSON serialization (would use jsoncpp in production)
     std::stringstream json;
     json << "{\n";
+    json << "  \"proof_version\": " << proof_version << ",\n";
     json << "  \"job_id\": \"" << job_id << "\",\n";
     json << "  \"code_hash\": \"" << code_hash << "\",\n";
     json << "  \"input_hash\": \"" << input_hash << "\",\n";
@@ -101,6 +200,26 @@ std::string ProofOfCompute::to_json() const {
     json << "  \"gpu_time\": " << gpu_time << ",\n";
     json << "  \"memory_peak\": " << memory_peak << ",\n";
     json << "  \"syscall_count\": " << syscall_count << ",\n";
+    json << "  \"work_units\": " << work_units() << ",\n";
+
+    if (descriptor) {
+        json << "  \"execution_descriptor\": {\"argv\": [";
+        for (size_t i = 0; i < descriptor->argv.size(); ++i) {
+            if (i > 0) json << ", ";
+            json << "\"" << json_escape(descriptor->argv[i]) << "\"";
+        }
+        json << "], \"work_dir\": \"" << json_escape(descriptor->work_dir) << "\""
+             << ", \"env_hash\": \"" << descriptor->env_hash << "\"},\n";
+    }
+
+    json << "  \"annotations\": {";
+    bool first = true;
+    for (const auto& [key, value] : annotations) {
+        if (!first) json << ", ";
+        json << "\"" << json_escape(key) << "\": \"" << json_escape(value) << "\"";
+        first = false;
+    }
+    json << "},\n";
     
     // Add timestamp
     auto time_t_timestamp = std::chrono::system_clock::to_time_t(timestamp);
@@ -112,10 +231,65 @@ std::string ProofOfCompute::to_json() const {
     return json.str();
 }
 
+bool ProofOfCompute::size_ok(const ProofLimits& limits, std::string* reason) const {
+    auto fail = [reason](const std::string& why) {
+        if (reason) *reason = why;
+        return false;
+    };
+
+    if (checkpoint_hashes.size() > limits.max_checkpoints) {
+        return fail("Proof too large: " + std::to_string(checkpoint_hashes.size()) +
+                    " checkpoints (max " + std::to_string(limits.max_checkpoints) + ")");
+    }
+
+    const std::pair<const char*, const std::string*> fields[] = {
+        {"job_id", &job_id}, {"code_hash", &code_hash}, {"input_hash", &input_hash},
+        {"output_hash", &output_hash}, {"execution_hash", &execution_hash}
+    };
+    for (const auto& [name, value] : fields) {
+        if (value->size() > limits.max_field_length) {
+            return fail(std::string("Proof too large: ") + name + " exceeds " +
+                        std::to_string(limits.max_field_length) + " bytes");
+        }
+    }
+
+    for (const auto& checkpoint : checkpoint_hashes) {
+        if (checkpoint.size() > limits.max_field_length) {
+            return fail("Proof too large: checkpoint hash exceeds " +
+                        std::to_string(limits.max_field_length) + " bytes");
+        }
+    }
+
+    if (annotations.size() > limits.max_annotations) {
+        return fail("Proof too large: " + std::to_string(annotations.size()) +
+                    " annotations (max " + std::to_string(limits.max_annotations) + ")");
+    }
+
+    size_t annotation_bytes = 0;
+    for (const auto& [key, value] : annotations) {
+        annotation_bytes += key.size() + value.size();
+    }
+    if (annotation_bytes > limits.max_annotation_bytes) {
+        return fail("Proof too large: annotations exceed " +
+                    std::to_string(limits.max_annotation_bytes) + " bytes");
+    }
+
+    return true;
+}
+
 bool ProofOfCompute::verify(const ExecutionTrace& trace) const {
     // Verify execution trace matches proof
     // In production, would do more thorough verification
-    
+
+    // Reject formats this node can't interpret, then oversized proofs,
+    // before doing any hashing work
+    if (proof_version < MIN_PROOF_VERSION || proof_version > PROOF_VERSION) {
+        return false;
+    }
+    if (!size_ok()) {
+        return false;
+    }
+
     if (trace.syscalls.size() != syscall_count) {
         return false;
     }
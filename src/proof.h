@@ -5,9 +5,20 @@
 #include <chrono>
 #include <cstdint>
 #include <memory>
+#include <map>
+#include <optional>
+#include "constants.h"
 
 namespace sandrun {
 
+// Size limits enforced before a proof is hashed or verified
+struct ProofLimits {
+    size_t max_checkpoints = MAX_PROOF_CHECKPOINTS;
+    size_t max_field_length = MAX_PROOF_FIELD_LENGTH;
+    size_t max_annotations = MAX_PROOF_ANNOTATIONS;
+    size_t max_annotation_bytes = MAX_PROOF_ANNOTATION_BYTES;
+};
+
 // Execution trace for proof-of-compute
 struct ExecutionTrace {
     struct Syscall {
@@ -34,8 +45,21 @@ struct ExecutionTrace {
     void clear();
 };
 
+// What a node actually ran. Environment values are reduced to a hash so
+// secrets never leave the node, while a submitter who knows the expected
+// environment can still check it
+struct ExecutionDescriptor {
+    std::vector<std::string> argv;   // Resolved command line, argv[0] included
+    std::string work_dir;
+    std::string env_hash;            // hash_env() of the job's environment
+
+    static std::string hash_env(const std::map<std::string, std::string>& env);
+    std::string calculate_hash() const;
+};
+
 // Proof of compute for a job
 struct ProofOfCompute {
+    uint32_t proof_version = PROOF_VERSION;  // Selects the canonical encoding
     std::string job_id;
     std::string code_hash;           // Hash of input code
     std::string input_hash;          // Hash of input data
@@ -47,16 +71,39 @@ struct ProofOfCompute {
     double gpu_time;                 // GPU seconds (if applicable)
     size_t memory_peak;              // Peak memory usage
     size_t syscall_count;            // Total syscalls made
+
+    // Command the node ran; folded into the proof hash when present
+    std::optional<ExecutionDescriptor> descriptor;
     
     std::chrono::system_clock::time_point timestamp;
+
+    // Free-form notes from the executing node (warnings, non-fatal stderr,
+    // environment details). Delivered with the proof but not hashed or
+    // verified, so they may differ between nodes that agree on the result
+    std::map<std::string, std::string> annotations;
     
-    // Generate deterministic proof hash
+    // Encoding the proof hash covers, per proof_version:
+    //   1: fields concatenated as text (ambiguous; verified, no longer written)
+    //   2: length-prefixed fields, exact hexfloat times
+    // Fails for versions outside [MIN_PROOF_VERSION, PROOF_VERSION]
+    std::optional<std::string> canonical_bytes(std::string* error = nullptr) const;
+
+    // Generate deterministic proof hash (excludes annotations);
+    // empty if the version is unsupported
     std::string calculate_hash() const;
+
+    // Compute performed, for weighting reputation and throughput: CPU
+    // seconds plus weighted GPU seconds and memory held over the run
+    double work_units() const;
     
     // Serialize to JSON
     std::string to_json() const;
     
-    // Verify proof matches execution
+    // Check proof is within size limits (reason set on failure)
+    bool size_ok(const ProofLimits& limits = ProofLimits{}, std::string* reason = nullptr) const;
+
+    // Verify proof matches execution (unsupported versions and oversized
+    // proofs are rejected first)
     bool verify(const ExecutionTrace& trace) const;
 };
 
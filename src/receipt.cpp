@@ -0,0 +1,103 @@
+#include "receipt.h"
+#include "file_utils.h"
+#include "constants.h"
+#include <sstream>
+#include <filesystem>
+#include <algorithm>
+
+namespace sandrun {
+
+std::string ExecutionReceipt::calculate_env_hash(const JobDefinition& job) {
+    std::ostringstream data;
+    data << RECEIPT_HASH_DOMAIN << ":env:"
+         << job.interpreter << "|"
+         << job.environment << "|";
+    if (!job.environment_lock.empty()) {
+        data << "lock:" << job.environment_lock << "|";
+    }
+    return FileUtils::sha256_string(data.str());
+}
+
+bool ExecutionReceipt::descriptor_matches(const ExecutionDescriptor& descriptor,
+                                          const JobDefinition& job) {
+    if (descriptor.argv.size() != job.args.size() + 2) {
+        return false;
+    }
+    // The interpreter may be resolved to a full path
+    if (std::filesystem::path(descriptor.argv[0]).filename() != job.interpreter) {
+        return false;
+    }
+    if (descriptor.argv[1] != job.entrypoint) {
+        return false;
+    }
+    return std::equal(job.args.begin(), job.args.end(), descriptor.argv.begin() + 2);
+}
+
+std::optional<ExecutionReceipt> ExecutionReceipt::build(const JobDefinition& job,
+                                                        const ProofOfCompute& proof,
+                                                        const WorkerIdentity& signer,
+                                                        std::string* error) {
+    // ProofGenerator hashes the raw entrypoint code
+    if (proof.code_hash != FileUtils::sha256_string(job.code)) {
+        if (error) *error = "Proof code hash does not match job code";
+        return std::nullopt;
+    }
+    if (!proof.canonical_bytes(error)) {
+        return std::nullopt;
+    }
+    if (proof.descriptor && !descriptor_matches(*proof.descriptor, job)) {
+        if (error) *error = "Executed command does not match job definition";
+        return std::nullopt;
+    }
+
+    ExecutionReceipt receipt;
+    receipt.job_hash = job.calculate_hash();
+    receipt.code_hash = proof.code_hash;
+    receipt.input_hash = proof.input_hash;
+    receipt.env_hash = calculate_env_hash(job);
+    receipt.output_hash = proof.output_hash;
+    receipt.execution_hash = proof.execution_hash;
+    receipt.descriptor_hash = proof.descriptor ? proof.descriptor->calculate_hash() : "";
+    receipt.worker_id = signer.get_worker_id();
+    receipt.signature = signer.sign(receipt.signing_data());
+    return receipt;
+}
+
+std::string ExecutionReceipt::signing_data() const {
+    std::ostringstream data;
+    data << RECEIPT_HASH_DOMAIN << ":"
+         << job_hash << "|"
+         << code_hash << "|"
+         << input_hash << "|"
+         << env_hash << "|"
+         << output_hash << "|"
+         << execution_hash << "|"
+         << descriptor_hash << "|"
+         << worker_id;
+    return data.str();
+}
+
+bool ExecutionReceipt::verify() const {
+    if (worker_id.empty() || signature.empty()) {
+        return false;
+    }
+    return WorkerIdentity::verify(signing_data(), signature, worker_id);
+}
+
+std::string ExecutionReceipt::to_json() const {
+    std::ostringstream json;
+    json << "{\n";
+    json << "  \"job_hash\": \"" << job_hash << "\",\n";
+    json << "  \"code_hash\": \"" << code_hash << "\",\n";
+    json << "  \"input_hash\": \"" << input_hash << "\",\n";
+    json << "  \"env_hash\": \"" << env_hash << "\",\n";
+    json << "  \"output_hash\": \"" << output_hash << "\",\n";
+    json << "  \"execution_hash\": \"" << execution_hash << "\",\n";
+    json << "  \"descriptor_hash\": \"" << descriptor_hash << "\",\n";
+    json << "  \"worker_id\": \"" << worker_id << "\",\n";
+    json << "  \"signature\": \"" << signature << "\"\n";
+    json << "}";
+    return json.str();
+}
+
+} // namespace sandrun
@@ -0,0 +1,48 @@
+#pragma once
+
+#include <string>
+#include <optional>
+#include "job_hash.h"
+#include "proof.h"
+#include "worker_identity.h"
+
+namespace sandrun {
+
+// Signed provenance record: which code, inputs and environment produced
+// which outputs, as attested by one worker. Contains no timestamps, so the
+// same deterministic job on the same worker yields a byte-identical receipt
+struct ExecutionReceipt {
+    std::string job_hash;        // JobDefinition::calculate_hash()
+    std::string code_hash;       // From the proof
+    std::string input_hash;      // From the proof
+    std::string env_hash;        // Interpreter + environment template
+    std::string output_hash;     // From the proof
+    std::string execution_hash;  // From the proof
+    std::string descriptor_hash; // ExecutionDescriptor hash; empty if the proof has none
+    std::string worker_id;       // Signer's public key (base64)
+    std::string signature;       // Ed25519 over signing_data() (base64)
+
+    // Build and sign a receipt; fails if the proof's code hash doesn't
+    // match the job's code, or its descriptor ran a different command
+    static std::optional<ExecutionReceipt> build(const JobDefinition& job,
+                                                 const ProofOfCompute& proof,
+                                                 const WorkerIdentity& signer,
+                                                 std::string* error = nullptr);
+
+    // True if a descriptor's argv is the job's interpreter, entrypoint and args
+    static bool descriptor_matches(const ExecutionDescriptor& descriptor,
+                                   const JobDefinition& job);
+
+    // Hash of the execution environment a job ran in
+    static std::string calculate_env_hash(const JobDefinition& job);
+
+    // Canonical bytes covered by the signature
+    std::string signing_data() const;
+
+    // Check the signature against worker_id
+    bool verify() const;
+
+    std::string to_json() const;
+};
+
+} // namespace sandrun
@@ -26,6 +26,67 @@
 namespace sandrun {
 namespace fs = std::filesystem;
 
+FailureReason classify_exit(int exit_code, int signal) {
+    if (signal == SIGSYS) {
+        return FailureReason::SANDBOX_VIOLATION;
+    }
+    // Nothing else SIGKILLs a sandboxed job: the OOM killer and cgroup
+    // limits do, and the timeout path is classified before this
+    if (signal == SIGKILL || signal == SIGXCPU || signal == SIGXFSZ) {
+        return FailureReason::RESOURCE_LIMIT;
+    }
+    if (signal != 0) {
+        return FailureReason::RUNTIME_CRASH;
+    }
+    return exit_code == 0 ? FailureReason::NONE : FailureReason::NONZERO_EXIT;
+}
+
+FailureReason classify_exit_status(int exit_status) {
+    if (exit_status > 128 && exit_status < 128 + NSIG) {
+        return classify_exit(exit_status, exit_status - 128);
+    }
+    if (exit_status < 0 && -exit_status < NSIG) {
+        return classify_exit(exit_status, -exit_status);
+    }
+    return classify_exit(exit_status, 0);
+}
+
+std::string failure_reason_to_string(FailureReason reason) {
+    switch (reason) {
+        case FailureReason::NONE: return "none";
+        case FailureReason::NONZERO_EXIT: return "nonzero_exit";
+        case FailureReason::RUNTIME_CRASH: return "runtime_crash";
+        case FailureReason::SANDBOX_VIOLATION: return "sandbox_violation";
+        case FailureReason::TIMEOUT: return "timeout";
+        case FailureReason::RESOURCE_LIMIT: return "resource_limit";
+        case FailureReason::CONTENT_POLICY: return "content_policy";
+    }
+    return "unknown";
+}
+
+bool is_retryable(FailureReason reason) {
+    // Only a crash can depend on the node; everything else repeats anywhere
+    return reason == FailureReason::RUNTIME_CRASH;
+}
+
+std::vector<size_t> GpuInfo::available_partitions(const GpuRequirements& req) const {
+    std::vector<size_t> result;
+    for (size_t i = 0; i < partitions.size(); i++) {
+        const auto& partition = partitions[i];
+        if (!partition.in_use &&
+            partition.memory_bytes >= req.min_memory_bytes &&
+            partition.compute_capability >= req.min_compute_capability) {
+            result.push_back(i);
+        }
+    }
+
+    // Best fit first; stable so equal slices keep device order
+    std::stable_sort(result.begin(), result.end(), [this](size_t a, size_t b) {
+        return partitions[a].memory_bytes < partitions[b].memory_bytes;
+    });
+    return result;
+}
+
 class Sandbox::Impl {
 public:
     Impl(const SandboxConfig& cfg) : config(cfg) {}
@@ -168,6 +229,9 @@ public:
             close(stderr_pipe[0]);
 
             result.exit_code = WIFEXITED(status) ? WEXITSTATUS(status) : -1;
+            result.term_signal = WIFSIGNALED(status) ? WTERMSIG(status) : 0;
+            result.failure_reason = timed_out ? FailureReason::TIMEOUT
+                                              : classify_exit(result.exit_code, result.term_signal);
             result.wall_time = std::chrono::duration_cast<std::chrono::milliseconds>(
                 std::chrono::steady_clock::now() - start_time);
             
@@ -3,16 +3,41 @@
 #include <string>
 #include <chrono>
 #include <memory>
+#include <vector>
 #include "constants.h"
 
 namespace sandrun {
 
+// Why a job did not succeed. A runtime crash (the interpreter killed by a
+// signal) may be specific to the node and is worth retrying elsewhere; a
+// nonzero exit, a sandbox violation or a blown resource limit is the job's
+// own doing and will fail the same way anywhere
+enum class FailureReason {
+    NONE,                // Exited 0
+    NONZERO_EXIT,        // Job logic exited with an error status
+    RUNTIME_CRASH,       // Killed by a signal (segfault, abort, ...)
+    SANDBOX_VIOLATION,   // Killed by seccomp for a forbidden syscall
+    TIMEOUT,             // Killed for exceeding its time limit
+    RESOURCE_LIMIT,      // Killed at a limit: SIGKILL (OOM/cgroup), SIGXCPU, SIGXFSZ
+    CONTENT_POLICY       // Wrote a file the operator's output content policy forbids
+};
+
+// Classify how a process ended; signal is the terminating signal, 0 if it exited
+FailureReason classify_exit(int exit_code, int signal);
+// Classify from an exit status alone, for runners that report no signal:
+// 128 + N (the shell convention) and -N both read as killed by signal N
+FailureReason classify_exit_status(int exit_status);
+std::string failure_reason_to_string(FailureReason reason);
+bool is_retryable(FailureReason reason);
+
 // Job execution result
 struct JobResult {
     std::string job_id;
     std::string output;
     std::string error;
     int exit_code;
+    int term_signal = 0;                              // Terminating signal, 0 if exited
+    FailureReason failure_reason = FailureReason::NONE;
     double cpu_seconds;
     size_t memory_bytes;
     std::chrono::milliseconds wall_time;
@@ -29,6 +54,30 @@ struct JobResult {
     ~JobResult() { clear(); }
 };
 
+// A schedulable slice of a GPU: a MIG instance, or the whole card when
+// MIG is disabled. Data model only: nothing discovers partitions, and the
+// sandbox still exposes whole devices, so no job is confined to a slice
+struct GpuPartition {
+    std::string uuid;                 // CUDA_VISIBLE_DEVICES value ("MIG-..." or "GPU-...")
+    size_t memory_bytes = 0;
+    int compute_capability = 0;       // major * 10 + minor, e.g. 80 for sm_80
+    bool in_use = false;
+};
+
+struct GpuRequirements {
+    size_t min_memory_bytes = 0;
+    int min_compute_capability = 0;
+};
+
+struct GpuInfo {
+    int device_id = 0;
+    std::vector<GpuPartition> partitions;
+
+    // Indices of free partitions that satisfy req, smallest memory first,
+    // so a 10GB job lands on a 20GB slice rather than a whole 80GB card
+    std::vector<size_t> available_partitions(const GpuRequirements& req) const;
+};
+
 // Sandbox configuration
 struct SandboxConfig {
     size_t memory_limit_bytes = DEFAULT_MEMORY_LIMIT_BYTES;
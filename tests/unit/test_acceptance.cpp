@@ -0,0 +1,73 @@
+#include <gtest/gtest.h>
+#include "acceptance.h"
+#include "constants.h"
+
+namespace sandrun {
+namespace {
+
+class JobAcceptanceTest : public ::testing::Test {
+protected:
+    void SetUp() override {
+        identity = WorkerIdentity::generate();
+        ASSERT_NE(identity, nullptr);
+    }
+
+    std::unique_ptr<WorkerIdentity> identity;
+};
+
+TEST_F(JobAcceptanceTest, BuildProducesVerifiableAcceptance) {
+    // Given: A worker accepting a queued job
+    // When: Building the acceptance
+    auto acceptance = JobAcceptance::build("job-1", "abc123", 1700000300, *identity);
+
+    // Then: It names the job and deadline and verifies against the worker's key
+    EXPECT_EQ(acceptance.job_id, "job-1");
+    EXPECT_EQ(acceptance.job_hash, "abc123");
+    EXPECT_EQ(acceptance.committed_deadline, 1700000300);
+    EXPECT_EQ(acceptance.worker_id, identity->get_worker_id());
+    EXPECT_TRUE(acceptance.verify());
+}
+
+TEST_F(JobAcceptanceTest, ExtendedDeadline_FailsVerification) {
+    // Given: A signed acceptance
+    auto acceptance = JobAcceptance::build("job-1", "abc123", 1700000300, *identity);
+
+    // When: The worker later claims it promised a later deadline
+    acceptance.committed_deadline += 3600;
+
+    // Then: The signature no longer covers it
+    EXPECT_FALSE(acceptance.verify());
+}
+
+TEST_F(JobAcceptanceTest, OtherWorkersKey_FailsVerification) {
+    auto acceptance = JobAcceptance::build("job-1", "abc123", 1700000300, *identity);
+    auto other = WorkerIdentity::generate();
+    acceptance.worker_id = other->get_worker_id();
+
+    EXPECT_FALSE(acceptance.verify());
+}
+
+TEST_F(JobAcceptanceTest, DeadlineFor_CoversQueueInFlightJobAndOverhead) {
+    // Given: A job submitted behind two others, with one more running
+    // When: Computing its deadline
+    int64_t deadline = JobAcceptance::deadline_for(1000, 3, 300);
+
+    // Then: Three queued jobs plus the running one each get a full timeout
+    // and the per-job start margin
+    EXPECT_EQ(deadline, 1000 + 4 * (300 + JOB_START_MARGIN_SECONDS));
+}
+
+TEST_F(JobAcceptanceTest, DeadlineFor_ScalesWithJobTimeout) {
+    EXPECT_GT(JobAcceptance::deadline_for(0, 1, 600), JobAcceptance::deadline_for(0, 1, 300));
+}
+
+TEST_F(JobAcceptanceTest, UnsignedAcceptance_FailsVerification) {
+    JobAcceptance acceptance;
+    acceptance.job_id = "job-1";
+    acceptance.committed_deadline = 1700000300;
+
+    EXPECT_FALSE(acceptance.verify());
+}
+
+} // namespace
+} // namespace sandrun
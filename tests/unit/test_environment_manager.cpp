@@ -702,6 +702,55 @@ TEST_F(EnvironmentManagerTest, BuiltInTemplatesAutoRegistered) {
         << "scientific should be auto-registered";
 }
 
+// ============================================================================
+// Environment Lock Tests
+// ============================================================================
+
+TEST_F(EnvironmentManagerTest, LockHashIgnoresPackageOrder) {
+    // Given: The same pinned packages listed in different orders
+    auto a = create_test_template("lock_a");
+    a.packages = {"numpy==1.26.4", "pandas==2.2.1"};
+    auto b = create_test_template("lock_b");
+    b.packages = {"pandas==2.2.1", "numpy==1.26.4"};
+
+    // Then: Both lock to the same environment
+    EXPECT_TRUE(a.is_pinned());
+    EXPECT_EQ(a.lock_hash(), b.lock_hash());
+    EXPECT_EQ(a.lock_hash().size(), 64u);
+}
+
+TEST_F(EnvironmentManagerTest, LockHashChangesWithVersionOrBaseImage) {
+    auto tmpl = create_test_template("lock_versions");
+    tmpl.packages = {"numpy==1.26.4"};
+    std::string original = tmpl.lock_hash();
+
+    auto bumped = tmpl;
+    bumped.packages = {"numpy==1.26.5"};
+    auto rebased = tmpl;
+    rebased.base_image = "python:3.12";
+
+    EXPECT_NE(bumped.lock_hash(), original);
+    EXPECT_NE(rebased.lock_hash(), original);
+}
+
+TEST_F(EnvironmentManagerTest, UnpinnedTemplateCannotSatisfyLock) {
+    // Given: A registered template with an unpinned package
+    auto tmpl = create_test_template("lock_unpinned");
+    tmpl.packages = {"numpy==1.26.4", "requests"};
+    mgr->register_template(tmpl);
+
+    // Then: The node advertises no lock for it
+    EXPECT_FALSE(tmpl.is_pinned());
+    EXPECT_FALSE(mgr->lock_hash(tmpl.name).has_value());
+    EXPECT_FALSE(mgr->lock_hash(test_prefix + "missing").has_value());
+
+    // When: Every package is pinned, the registered lock is advertised
+    tmpl.packages = {"numpy==1.26.4", "requests==2.31.0"};
+    mgr->register_template(tmpl);
+    ASSERT_TRUE(mgr->lock_hash(tmpl.name).has_value());
+    EXPECT_EQ(*mgr->lock_hash(tmpl.name), tmpl.lock_hash());
+}
+
 // ============================================================================
 // Error Handling Tests
 // ============================================================================
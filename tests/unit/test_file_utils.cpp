@@ -1,8 +1,10 @@
 #include <gtest/gtest.h>
 #include "file_utils.h"
 #include <fstream>
+#include <sstream>
 #include <filesystem>
 #include <vector>
+#include <algorithm>
 
 namespace sandrun {
 namespace {
@@ -458,6 +460,148 @@ TEST_F(FileUtilsTest, BytesToHex_EmptyInput) {
     EXPECT_EQ(hex, "") << "Empty input should produce empty string";
 }
 
+// ============================================================================
+// Streaming Verification Tests
+// ============================================================================
+
+TEST_F(FileUtilsTest, ChunkHashFile_SplitsIntoChunks) {
+    // Given: A 10-byte file and a 4-byte chunk size
+    // When: Building its chunk tree
+    // Then: Should produce 3 chunks (4 + 4 + 2) with a root
+
+    std::string filepath = create_test_file("chunks.txt", "0123456789");
+    ChunkTree tree = FileUtils::chunk_hash_file(filepath, 4);
+
+    ASSERT_EQ(tree.chunk_hashes.size(), 3);
+    EXPECT_EQ(tree.total_size, 10);
+    EXPECT_EQ(tree.chunk_hashes[0], FileUtils::sha256_string("0123"));
+    EXPECT_EQ(tree.chunk_hashes[2], FileUtils::sha256_string("89"));
+    EXPECT_EQ(tree.root_hash, FileUtils::chunk_tree_root(tree));
+}
+
+TEST_F(FileUtilsTest, VerifyStream_AcceptsMatchingContent) {
+    // Given: A chunk tree for a file
+    // When: Verifying a stream with the same content
+    // Then: Should succeed
+
+    std::string filepath = create_test_file("stream.txt", "0123456789");
+    ChunkTree tree = FileUtils::chunk_hash_file(filepath, 4);
+
+    std::istringstream in("0123456789");
+    std::string error;
+    EXPECT_TRUE(FileUtils::verify_stream(in, tree, tree.root_hash, &error)) << error;
+}
+
+TEST_F(FileUtilsTest, VerifyStream_StopsAtFirstBadChunk) {
+    // Given: A stream corrupted in its second chunk
+    // When: Verifying it
+    // Then: Should fail naming chunk 1 without reading past it
+
+    std::string filepath = create_test_file("stream.txt", "0123456789");
+    ChunkTree tree = FileUtils::chunk_hash_file(filepath, 4);
+
+    std::istringstream in("0123X56789");
+    std::string error;
+    EXPECT_FALSE(FileUtils::verify_stream(in, tree, tree.root_hash, &error));
+    EXPECT_EQ(error, "chunk 1 hash mismatch");
+    EXPECT_EQ(in.tellg(), 8) << "Should not read beyond the bad chunk";
+}
+
+TEST_F(FileUtilsTest, VerifyStream_RejectsTruncatedAndExtendedStreams) {
+    std::string filepath = create_test_file("stream.txt", "0123456789");
+    ChunkTree tree = FileUtils::chunk_hash_file(filepath, 4);
+
+    std::istringstream truncated("01234567");
+    EXPECT_FALSE(FileUtils::verify_stream(truncated, tree, tree.root_hash));
+
+    std::istringstream extended("0123456789AB");
+    EXPECT_FALSE(FileUtils::verify_stream(extended, tree, tree.root_hash));
+}
+
+TEST_F(FileUtilsTest, VerifyStream_RejectsTamperedTree) {
+    // Given: A tree whose chunk hash was swapped without updating the root
+    // When: Verifying content that matches the swapped hash
+    // Then: Should fail on the root before trusting any chunk hash
+
+    std::string filepath = create_test_file("stream.txt", "0123456789");
+    ChunkTree tree = FileUtils::chunk_hash_file(filepath, 4);
+    std::string signed_root = tree.root_hash;
+    tree.chunk_hashes[0] = FileUtils::sha256_string("abcd");
+
+    std::istringstream in("abcd456789");
+    std::string error;
+    EXPECT_FALSE(FileUtils::verify_stream(in, tree, signed_root, &error));
+    EXPECT_EQ(error, "chunk tree root mismatch");
+}
+
+TEST_F(FileUtilsTest, VerifyStream_RejectsRebuiltTree) {
+    // Given: A self-consistent tree built over substituted content
+    // When: Verifying that content against the root the worker signed
+    // Then: Should fail, since the tree no longer matches the signed root
+
+    std::string filepath = create_test_file("stream.txt", "0123456789");
+    std::string signed_root = FileUtils::chunk_hash_file(filepath, 4).root_hash;
+    std::string forged_path = create_test_file("forged.txt", "abcdefghij");
+    ChunkTree forged = FileUtils::chunk_hash_file(forged_path, 4);
+
+    std::istringstream in("abcdefghij");
+    std::string error;
+    EXPECT_FALSE(FileUtils::verify_stream(in, forged, signed_root, &error));
+    EXPECT_EQ(error, "chunk tree root mismatch");
+}
+
+TEST_F(FileUtilsTest, HashDirectory_RecordsChunkRoot) {
+    std::string filepath = create_test_file("out.bin", "0123456789");
+
+    auto files = FileUtils::hash_directory(test_dir.string());
+
+    ASSERT_EQ(files.count("out.bin"), 1u);
+    EXPECT_EQ(files["out.bin"].chunk_root, FileUtils::chunk_hash_file(filepath).root_hash);
+}
+
+// ============================================================================
+// Outputs Digest Tests
+// ============================================================================
+
+TEST_F(FileUtilsTest, OutputsHash_IndependentOfOrder) {
+    // Given: The same outputs collected in different orders
+    std::vector<std::pair<std::string, std::string>> outputs = {
+        {"model.pt", FileUtils::sha256_string("weights")},
+        {"metrics.json", FileUtils::sha256_string("{}")},
+        {"logs/train.log", FileUtils::sha256_string("epoch 1")},
+        {"a.txt", FileUtils::sha256_string("a")},
+    };
+    std::string expected = FileUtils::outputs_hash(outputs);
+
+    // When: Hashing every permutation
+    std::sort(outputs.begin(), outputs.end());
+    do {
+        // Then: The digest never changes
+        EXPECT_EQ(FileUtils::outputs_hash(outputs), expected);
+    } while (std::next_permutation(outputs.begin(), outputs.end()));
+}
+
+TEST_F(FileUtilsTest, OutputsHash_MatchesDirectoryMetadata) {
+    create_test_file("b.txt", "b");
+    create_test_file("a.txt", "a");
+
+    auto files = FileUtils::hash_directory(test_dir.string());
+
+    EXPECT_EQ(FileUtils::outputs_hash(files), FileUtils::outputs_hash({
+        {"b.txt", FileUtils::sha256_string("b")},
+        {"a.txt", FileUtils::sha256_string("a")},
+    }));
+}
+
+TEST_F(FileUtilsTest, OutputsHash_BindsNamesToHashes) {
+    std::string h1 = FileUtils::sha256_string("one");
+    std::string h2 = FileUtils::sha256_string("two");
+
+    // Swapping which output has which content changes the digest
+    EXPECT_NE(FileUtils::outputs_hash({{"a", h1}, {"b", h2}}),
+              FileUtils::outputs_hash({{"a", h2}, {"b", h1}}));
+}
+
 // ============================================================================
 // File Type Detection Tests
 // ============================================================================
@@ -710,5 +854,77 @@ TEST_F(FileUtilsTest, GetFileMetadata_Directory) {
     EXPECT_EQ(metadata.type, FileType::OTHER);
 }
 
+// ============================================================================
+// Content Sniffing and Policy Tests
+// ============================================================================
+
+TEST_F(FileUtilsTest, SniffMimeType_IgnoresMisleadingExtension) {
+    // Given: An ELF binary named like a text file, and a real text file
+    std::string binary = create_test_file("notes.txt", std::string("\x7f" "ELF\x02\x01\x01", 7));
+    std::string text = create_test_file("readme.txt", "hello");
+
+    // Then: The binary sniffs as an executable, the text falls back to its name
+    EXPECT_EQ(FileUtils::sniff_mime_type(binary), "application/x-executable");
+    EXPECT_EQ(FileUtils::sniff_mime_type(text), FileUtils::get_mime_type("readme.txt"));
+}
+
+TEST_F(FileUtilsTest, SniffMimeType_RequiresPeHeaderForWindowsImages) {
+    // Given: A minimal PE image, and a text file that happens to start with "MZ"
+    std::string image(0x44, '\0');
+    image.replace(0, 2, "MZ");
+    image[0x3C] = 0x40;
+    image.replace(0x40, 4, std::string("PE\0\0", 4));
+    std::string pe = create_test_file("setup.dat", image);
+    std::string text = create_test_file("names.txt", "MZ Smith\nAB Jones\n");
+
+    // Then: Only the image is classified as a Windows executable
+    EXPECT_EQ(FileUtils::sniff_mime_type(pe), "application/x-msdownload");
+    EXPECT_EQ(FileUtils::sniff_mime_type(text), FileUtils::get_mime_type("names.txt"));
+}
+
+TEST_F(FileUtilsTest, SniffMimeType_RejectsPeOffsetPastEndOfFile) {
+    std::string image(0x40, '\0');
+    image.replace(0, 2, "MZ");
+    image[0x3C] = '\x7f';
+    image[0x3F] = '\x7f';
+    std::string path = create_test_file("truncated.bin", image);
+
+    EXPECT_NE(FileUtils::sniff_mime_type(path), "application/x-msdownload");
+}
+
+TEST_F(FileUtilsTest, ContentPolicy_AllowAndBlockLists) {
+    ContentPolicy policy;
+    policy.allowed = {"image/*", "text/csv"};
+    policy.blocked = {"image/gif"};
+
+    EXPECT_TRUE(policy.permits("image/png"));
+    EXPECT_TRUE(policy.permits("text/csv"));
+    EXPECT_FALSE(policy.permits("image/gif")) << "Blocked wins over allowed";
+    EXPECT_FALSE(policy.permits("application/x-executable"));
+    EXPECT_TRUE(ContentPolicy{}.permits("application/x-executable"));
+}
+
+TEST_F(FileUtilsTest, CheckContentPolicy_ReportsForbiddenOutputs) {
+    // Given: A job that produced a result and a disguised executable
+    create_test_file("result.csv", "a,b\n1,2\n");
+    create_test_file("tool.dat", std::string("\x7f" "ELF\x02", 5));
+    auto files = FileUtils::hash_directory(test_dir.string());
+    ContentPolicy policy;
+    policy.blocked = {"application/x-executable"};
+
+    // When: Checking the outputs
+    std::vector<std::string> forbidden;
+    auto error = FileUtils::check_content_policy(test_dir.string(), files, policy, &forbidden);
+
+    // Then: Only the executable is flagged, with a reason naming it
+    ASSERT_TRUE(error);
+    EXPECT_NE(error->find("tool.dat"), std::string::npos);
+    EXPECT_NE(error->find("application/x-executable"), std::string::npos);
+    EXPECT_EQ(forbidden, std::vector<std::string>{"tool.dat"});
+
+    policy.blocked = {"application/x-msdownload"};
+    EXPECT_FALSE(FileUtils::check_content_policy(test_dir.string(), files, policy));
+}
+
 } // namespace
 } // namespace sandrun
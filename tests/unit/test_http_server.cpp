@@ -389,6 +389,44 @@ TEST_F(HttpServerTest, MapsStatusCodesToReasonPhrases) {
     }
 }
 
+TEST_F(HttpServerTest, MapsApiErrorsToStatusCodes) {
+    // Given: Each API error category
+    // When: Mapping to HTTP and gRPC codes
+    // Then: Should use the canonical codes
+
+    EXPECT_EQ(status_code_for(ApiError::INVALID_REQUEST), 400);
+    EXPECT_EQ(status_code_for(ApiError::UNAUTHORIZED), 401);
+    EXPECT_EQ(status_code_for(ApiError::FORBIDDEN), 403);
+    EXPECT_EQ(status_code_for(ApiError::NOT_FOUND), 404);
+    EXPECT_EQ(status_code_for(ApiError::VALIDATION_FAILED), 422);
+    EXPECT_EQ(status_code_for(ApiError::RATE_LIMITED), 429);
+    EXPECT_EQ(status_code_for(ApiError::NO_CAPACITY), 503);
+    EXPECT_EQ(status_code_for(ApiError::UNSUPPORTED), 501);
+    EXPECT_EQ(status_code_for(ApiError::INTERNAL), 500);
+
+    EXPECT_EQ(grpc_code_for(ApiError::NOT_FOUND), 5);
+    EXPECT_EQ(grpc_code_for(ApiError::RATE_LIMITED), 8);
+    EXPECT_EQ(grpc_code_for(ApiError::UNSUPPORTED), 12);
+    EXPECT_EQ(grpc_code_for(ApiError::INTERNAL), 13);
+}
+
+TEST_F(HttpServerTest, BuildsErrorResponseFromApiError) {
+    HttpResponse resp = error_response(ApiError::NOT_FOUND, "Job not found");
+
+    EXPECT_EQ(resp.status_code, 404);
+    EXPECT_EQ(resp.body, "{\"error\":\"Job not found\"}");
+
+    std::string raw = HttpServer::build_response(resp);
+    EXPECT_EQ(raw.substr(0, 22), "HTTP/1.1 404 Not Found");
+}
+
+TEST_F(HttpServerTest, IncludesReasonPhraseForRateLimiting) {
+    HttpResponse resp = error_response(ApiError::RATE_LIMITED, "Rate limit exceeded");
+    std::string raw = HttpServer::build_response(resp);
+
+    EXPECT_NE(raw.find("429 Too Many Requests"), std::string::npos);
+}
+
 // ============================================================================
 // Test Contract: Concurrent Request Handling
 // ============================================================================
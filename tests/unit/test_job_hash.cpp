@@ -1,5 +1,8 @@
 #include <gtest/gtest.h>
 #include "job_hash.h"
+#include <cstdint>
+#include "file_utils.h"
+#include "constants.h"
 
 using namespace sandrun;
 
@@ -269,15 +272,231 @@ TEST_F(JobHashTest, ManyArgs_HandledCorrectly) {
 }
 
 // ============================================================================
-// Separator Handling Tests
+// Domain Separation Tests
 // ============================================================================
 
-TEST_F(JobHashTest, PipeCharacterInFields_CausesKnownCollision) {
-    // Given: Two jobs where pipe character causes hash collision
-    // Note: This is a KNOWN LIMITATION of the current hash implementation
-    // The separator is '|', so "main|extra" + "python3" has same hash as
-    // "main" + "extra|python3" because both result in "main|extra|python3|..."
+TEST_F(JobHashTest, JobHash_IsDomainSeparated) {
+    // Given: A job definition
+    JobDefinition job = create_basic_job();
+
+    // When: Hashing the same fields without the job domain prefix
+    std::string undomained = FileUtils::sha256_string(
+        job.entrypoint + "|" + job.interpreter + "|" + job.environment + "|" + job.code);
+
+    // Then: The job hash cannot be reproduced outside its domain
+    EXPECT_NE(job.calculate_hash(), undomained);
+}
+
+// ============================================================================
+// Egress Allowlist Tests
+// ============================================================================
+
+TEST_F(JobHashTest, EgressHost_DoesNotCollideWithArg) {
+    // Given: An arg spelled like an egress entry, and the real allowlist
+    JobDefinition job1 = create_basic_job();
+    job1.args = {"egress:pypi.org"};
+
+    JobDefinition job2 = create_basic_job();
+    job2.allowed_egress = {"pypi.org"};
+
+    // When/Then: Framed fields keep the two apart
+    EXPECT_NE(job1.calculate_hash(), job2.calculate_hash());
+}
+
+TEST_F(JobHashTest, AllowedEgress_AffectsHash) {
+    // Given: Two jobs differing only in allowed egress
+    JobDefinition job1 = create_basic_job();
+    JobDefinition job2 = create_basic_job();
+    job2.allowed_egress = {"data.example.com"};
+
+    // When/Then: The allowlist is part of the job commitment
+    EXPECT_NE(job1.calculate_hash(), job2.calculate_hash());
+}
 
+TEST_F(JobHashTest, AllowedEgress_OrderAndCaseInsensitive) {
+    // Given: Equivalent allowlists written differently
+    JobDefinition job1 = create_basic_job();
+    job1.allowed_egress = {"pypi.org", "Files.PythonHosted.org"};
+
+    JobDefinition job2 = create_basic_job();
+    job2.allowed_egress = {"files.pythonhosted.org", "pypi.org", "pypi.org"};
+
+    // When/Then: They hash identically
+    EXPECT_EQ(job1.calculate_hash(), job2.calculate_hash());
+}
+
+// ============================================================================
+// Seed Derivation Tests
+// ============================================================================
+
+TEST_F(JobHashTest, DeriveSeed_SameJobSameSeed) {
+    // Given: The same job defined on two nodes
+    JobDefinition job1 = create_basic_job();
+    JobDefinition job2 = create_basic_job();
+
+    // When/Then: Both derive the same seed, and it fits a signed int64
+    EXPECT_EQ(job1.derive_seed(), job2.derive_seed());
+    EXPECT_LE(job1.derive_seed(), static_cast<uint64_t>(INT64_MAX));
+}
+
+TEST_F(JobHashTest, DeriveSeed_DiffersBetweenJobs) {
+    JobDefinition job1 = create_basic_job();
+    JobDefinition job2 = create_basic_job();
+    job2.code = "print('other')";
+
+    EXPECT_NE(job1.derive_seed(), job2.derive_seed());
+}
+
+TEST_F(JobHashTest, SeedArg_DoesNotCollideWithExplicitSeed) {
+    // Given: An arg spelled like a seed entry, and a real explicit seed
+    JobDefinition job1 = create_basic_job();
+    job1.args = {"seed:7"};
+
+    JobDefinition job2 = create_basic_job();
+    job2.random_seed = 7;
+
+    // When/Then: The seed has its own framed slot
+    EXPECT_NE(job1.calculate_hash(), job2.calculate_hash());
+}
+
+TEST_F(JobHashTest, ExplicitSeed_OverridesDerivedSeed) {
+    // Given: A job with an explicit manifest seed
+    JobDefinition job = create_basic_job();
+    std::string unseeded_hash = job.calculate_hash();
+    job.random_seed = 1234;
+
+    // Then: The explicit seed is used and becomes part of the commitment
+    EXPECT_EQ(job.derive_seed(), 1234u);
+    EXPECT_NE(job.calculate_hash(), unseeded_hash);
+}
+
+TEST_F(JobHashTest, EnvironmentLock_IsPartOfCommitment) {
+    // Given: The same job with and without an environment lock
+    JobDefinition job = create_basic_job();
+    std::string unlocked_hash = job.calculate_hash();
+    job.environment_lock = std::string(64, 'a');
+
+    // Then: Locking changes the hash, and different locks hash differently
+    std::string locked_hash = job.calculate_hash();
+    EXPECT_NE(locked_hash, unlocked_hash);
+    job.environment_lock = std::string(64, 'b');
+    EXPECT_NE(job.calculate_hash(), locked_hash);
+}
+
+// ============================================================================
+// Cache Key Tests
+// ============================================================================
+
+TEST_F(JobHashTest, CacheKey_IgnoresExcludedArgs) {
+    // Given: Two runs that differ only in a logging request ID
+    JobDefinition job1 = create_basic_job();
+    job1.args = {"--epochs", "5", "--request-id=a1", "--no-cache"};
+    job1.cache_exclude_args = {"--request-id", "--no-cache"};
+    JobDefinition job2 = job1;
+    job2.args = {"--request-id=b2", "--epochs", "5"};
+
+    // Then: They share a cache key but keep distinct job hashes
+    EXPECT_EQ(job1.cache_key(), job2.cache_key());
+    EXPECT_NE(job1.calculate_hash(), job2.calculate_hash());
+
+    // And: The key is the hash of the job without the excluded flag
+    JobDefinition plain = create_basic_job();
+    plain.args = {"--epochs", "5"};
+    EXPECT_EQ(job1.cache_key(), plain.calculate_hash());
+}
+
+TEST_F(JobHashTest, CacheKey_KeepsRelevantAndPositionalArgs) {
+    // Given: An excluded flag that also appears after "--"
+    JobDefinition job1 = create_basic_job();
+    job1.args = {"--epochs", "5", "--", "--request-id", "a1"};
+    job1.cache_exclude_args = {"--request-id"};
+    JobDefinition job2 = job1;
+    job2.args = {"--epochs", "6", "--", "--request-id", "a1"};
+    JobDefinition job3 = job1;
+    job3.args = {"--epochs", "5", "--", "--request-id", "b2"};
+
+    // Then: Non-excluded and positional arguments still change the key
+    EXPECT_NE(job1.cache_key(), job2.cache_key());
+    EXPECT_NE(job1.cache_key(), job3.cache_key());
+}
+
+TEST_F(JobHashTest, CacheKey_KeepsArgumentAfterExcludedFlag) {
+    // Given: An excluded flag followed by separate arguments
+    JobDefinition job1 = create_basic_job();
+    job1.args = {"--request-id", "--epochs", "5"};
+    job1.cache_exclude_args = {"--request-id"};
+    JobDefinition job2 = job1;
+    job2.args = {"--request-id", "--epochs", "6"};
+    JobDefinition plain = create_basic_job();
+    plain.args = {"--epochs", "5"};
+
+    // Then: Only the flag itself is dropped, never the argument after it
+    EXPECT_EQ(job1.cache_key(), plain.calculate_hash());
+    EXPECT_NE(job1.cache_key(), job2.cache_key());
+
+    // And: A flag that merely starts with an excluded one is kept
+    job2.args = {"--request-idx=1", "--epochs", "5"};
+    EXPECT_NE(job2.cache_key(), plain.calculate_hash());
+}
+
+TEST_F(JobHashTest, CacheKey_EqualsJobHashWithoutExclusions) {
+    JobDefinition job = create_basic_job();
+    EXPECT_EQ(job.cache_key(), job.calculate_hash());
+}
+
+// ============================================================================
+// Argument Normalization Tests
+// ============================================================================
+
+TEST_F(JobHashTest, NormalizeArgs_SplitsLongFlagAssignments) {
+    // Given: The same invocation spelled two ways
+    std::vector<std::string> joined = {"--epochs=5", "--lr", "0.1", "-v"};
+    std::vector<std::string> split = {"--epochs", "5", "--lr", "0.1", "-v"};
+
+    // When: Normalizing both
+    auto a = normalize_args(joined, "python3");
+    auto b = normalize_args(split, "python3");
+
+    // Then: They become identical
+    ASSERT_TRUE(a && b);
+    EXPECT_EQ(*a, split);
+    EXPECT_EQ(*a, *b);
+}
+
+TEST_F(JobHashTest, NormalizeArgs_LeavesPositionalArgsAlone) {
+    // Given: Arguments after "--", plus forms that are not long-flag assignments
+    std::vector<std::string> args = {"-n=5", "--=x", "--", "--keep=this"};
+
+    auto normalized = normalize_args(args, "node");
+
+    ASSERT_TRUE(normalized);
+    EXPECT_EQ(*normalized, args);
+}
+
+TEST_F(JobHashTest, NormalizeArgs_RejectsUnknownInterpreter) {
+    std::string error;
+    auto normalized = normalize_args({"--a=1"}, "ruby", &error);
+
+    EXPECT_FALSE(normalized);
+    EXPECT_NE(error.find("ruby"), std::string::npos) << error;
+}
+
+TEST_F(JobHashTest, NormalizeArgs_EquivalentJobsHashTheSame) {
+    JobDefinition job1 = create_basic_job();
+    job1.args = *normalize_args({"--n=5"}, job1.interpreter);
+
+    JobDefinition job2 = create_basic_job();
+    job2.args = *normalize_args({"--n", "5"}, job2.interpreter);
+
+    EXPECT_EQ(job1.calculate_hash(), job2.calculate_hash());
+}
+
+// ============================================================================
+// Separator Handling Tests
+// ============================================================================
+
+TEST_F(JobHashTest, PipeCharacterInFields_DoesNotCollide) {
+    // Given: Two jobs that would concatenate to the same "|"-joined bytes
     JobDefinition job1 = create_basic_job();
     job1.entrypoint = "main|extra";
     job1.interpreter = "python3";
@@ -290,13 +509,8 @@ TEST_F(JobHashTest, PipeCharacterInFields_CausesKnownCollision) {
     std::string hash1 = job1.calculate_hash();
     std::string hash2 = job2.calculate_hash();
 
-    // Then: These WILL collide due to pipe separator (known limitation)
-    EXPECT_EQ(hash1, hash2) << "Pipe character in fields causes known hash collision";
-
-    // This is acceptable because:
-    // 1. Pipe characters in filenames/interpreters are extremely rare
-    // 2. Fixing would require length-prefixing which is more complex
-    // 3. The collision only happens with malicious/unusual inputs
+    // Then: Length prefixes keep field boundaries unambiguous
+    EXPECT_NE(hash1, hash2);
 }
 
 TEST_F(JobHashTest, EmptyArg_AffectsHash) {
@@ -1,5 +1,7 @@
 #include <gtest/gtest.h>
 #include "proof.h"
+#include "file_utils.h"
+#include "constants.h"
 #include <thread>
 
 namespace sandrun {
@@ -102,6 +104,55 @@ TEST(ProofOfComputeTest, CalculateHash) {
     EXPECT_NE(hash1, hash3);
 }
 
+TEST(ProofOfComputeTest, CalculateHash_V1KeepsBaselineEncoding) {
+    // Given: A v1 proof (plain concatenated encoding) with a few fields set
+    ProofOfCompute proof;
+    proof.proof_version = 1;
+    proof.job_id = "test_job";
+    proof.code_hash = "abc123";
+    proof.cpu_time = 0;
+    proof.gpu_time = 0;
+    proof.memory_peak = 0;
+    proof.syscall_count = 0;
+
+    // Then: It hashes exactly the bytes v1 proofs were signed over
+    EXPECT_EQ(proof.calculate_hash(), FileUtils::sha256_string("test_jobabc1230000"));
+}
+
+TEST(ProofOfComputeTest, CalculateHash_IsDomainSeparated) {
+    // Given: A current-version proof with a few fields set
+    ProofOfCompute proof;
+    proof.job_id = "test_job";
+    proof.code_hash = "abc123";
+    proof.cpu_time = 0;
+    proof.gpu_time = 0;
+    proof.memory_peak = 0;
+    proof.syscall_count = 0;
+
+    // Then: Its encoding carries the proof domain, and so does a checkpoint
+    auto bytes = proof.canonical_bytes();
+    ASSERT_TRUE(bytes);
+    EXPECT_EQ(bytes->rfind(std::string(PROOF_V2_HASH_DOMAIN) + ":", 0), 0u);
+
+    ExecutionTrace trace;
+    EXPECT_NE(trace.create_checkpoint(), FileUtils::sha256_string("syscalls:0,files:0"));
+}
+
+TEST(ProofOfComputeTest, Descriptor_NeedsVersionTwo) {
+    // Given: A v1 proof carrying a descriptor v1 has no slot for
+    ProofOfCompute proof;
+    proof.proof_version = 1;
+    proof.descriptor = ExecutionDescriptor{{"python3", "main.py"}, "/work", ""};
+
+    // When: Encoding it
+    std::string error;
+    auto bytes = proof.canonical_bytes(&error);
+
+    // Then: It is refused rather than silently dropping the descriptor
+    EXPECT_FALSE(bytes);
+    EXPECT_NE(error.find("version 2"), std::string::npos) << error;
+}
+
 TEST(ProofOfComputeTest, ProofWithCheckpoints) {
     ProofOfCompute proof;
     proof.job_id = "long_job";
@@ -205,6 +256,244 @@ TEST(ProofOfComputeTest, Verify_FailsForWrongCheckpoints) {
         << "Proof should not verify with wrong checkpoints";
 }
 
+TEST(ProofOfComputeTest, SizeOk_AcceptsNormalProof) {
+    // Given: A proof from a short execution
+    ProofGenerator gen;
+    gen.start_recording("job1", "code");
+    gen.record_syscall(1, 10, 20);
+    gen.checkpoint();
+    ProofOfCompute proof = gen.generate_proof("output", 1.0, 1000);
+
+    // Then: It is within default limits
+    std::string reason;
+    EXPECT_TRUE(proof.size_ok(ProofLimits{}, &reason)) << reason;
+}
+
+TEST(ProofOfComputeTest, SizeOk_RejectsTooManyCheckpoints) {
+    // Given: Tight limits and a proof exceeding the checkpoint count
+    ProofLimits limits;
+    limits.max_checkpoints = 2;
+
+    ProofOfCompute proof;
+    proof.checkpoint_hashes = {"a", "b", "c"};
+
+    // When: Checking size
+    std::string reason;
+    bool ok = proof.size_ok(limits, &reason);
+
+    // Then: It is rejected with a reason naming the limit
+    EXPECT_FALSE(ok);
+    EXPECT_NE(reason.find("checkpoints"), std::string::npos) << reason;
+}
+
+TEST(ProofOfComputeTest, SizeOk_RejectsOversizedField) {
+    // Given: A proof whose output hash is far longer than any real digest
+    ProofOfCompute proof;
+    proof.output_hash = std::string(MAX_PROOF_FIELD_LENGTH + 1, 'f');
+
+    // When: Checking size
+    std::string reason;
+    bool ok = proof.size_ok(ProofLimits{}, &reason);
+
+    // Then: It is rejected
+    EXPECT_FALSE(ok);
+    EXPECT_NE(reason.find("output_hash"), std::string::npos) << reason;
+}
+
+TEST(ProofOfComputeTest, Verify_RejectsOversizedProof) {
+    // Given: An otherwise matching proof/trace pair with too many checkpoints
+    ExecutionTrace trace;
+    ProofOfCompute proof;
+    proof.syscall_count = 0;
+    for (size_t i = 0; i <= MAX_PROOF_CHECKPOINTS; ++i) {
+        trace.checkpoints.push_back("c");
+    }
+    proof.checkpoint_hashes = trace.checkpoints;
+    proof.execution_hash = FileUtils::sha256_string("");
+
+    // Then: Verification fails on size alone
+    EXPECT_FALSE(proof.verify(trace));
+}
+
+TEST(ProofOfComputeTest, Annotations_ExcludedFromHash) {
+    // Given: Two nodes' proofs of the same execution with different notes
+    ProofGenerator gen;
+    gen.start_recording("job1", "code");
+    gen.record_syscall(1, 10, 20);
+    ProofOfCompute proof1 = gen.generate_proof("output", 1.0, 1000);
+    ProofOfCompute proof2 = proof1;
+    proof1.annotations["warning"] = "DeprecationWarning: np.float";
+    proof2.annotations["node"] = "cuda 12.2";
+
+    // Then: They still agree on the proof hash
+    EXPECT_EQ(proof1.calculate_hash(), proof2.calculate_hash());
+}
+
+TEST(ProofOfComputeTest, Annotations_DeliveredInJson) {
+    ProofOfCompute proof;
+    proof.annotations["stderr"] = "line \"one\"\nline two";
+
+    std::string json = proof.to_json();
+
+    EXPECT_NE(json.find("\"annotations\": {\"stderr\": \"line \\\"one\\\"\\nline two\"}"),
+              std::string::npos) << json;
+}
+
+TEST(ProofOfComputeTest, SizeOk_RejectsOversizedAnnotations) {
+    // Given: Tight annotation limits
+    ProofLimits limits;
+    limits.max_annotations = 2;
+    limits.max_annotation_bytes = 16;
+
+    ProofOfCompute proof;
+    proof.annotations = {{"a", "1"}, {"b", "2"}, {"c", "3"}};
+
+    // Then: Too many entries is rejected
+    std::string reason;
+    EXPECT_FALSE(proof.size_ok(limits, &reason));
+    EXPECT_NE(reason.find("annotations"), std::string::npos) << reason;
+
+    // And: So is too much text in few entries
+    proof.annotations = {{"stderr", std::string(32, 'x')}};
+    EXPECT_FALSE(proof.size_ok(limits, &reason));
+}
+
+TEST(ProofOfComputeTest, Descriptor_FoldedIntoHash) {
+    // Given: A proof without a descriptor
+    ProofOfCompute proof;
+    proof.job_id = "job-1";
+    proof.cpu_time = 1.0;
+    proof.gpu_time = 0.0;
+    proof.memory_peak = 0;
+    proof.syscall_count = 0;
+    std::string plain_hash = proof.calculate_hash();
+
+    // When: Recording the command that ran
+    ExecutionDescriptor descriptor;
+    descriptor.argv = {"/usr/bin/python3", "main.py", "--epochs", "5"};
+    descriptor.work_dir = "/tmp/sandrun/job-1";
+    descriptor.env_hash = ExecutionDescriptor::hash_env({{"SANDRUN_SEED", "42"}});
+    proof.descriptor = descriptor;
+    std::string described_hash = proof.calculate_hash();
+
+    // Then: The proof hash covers it, so an altered command changes the hash
+    EXPECT_NE(described_hash, plain_hash);
+    proof.descriptor->argv.back() = "500";
+    EXPECT_NE(proof.calculate_hash(), described_hash);
+}
+
+TEST(ProofOfComputeTest, Descriptor_EnvHashHidesValues) {
+    std::string hash = ExecutionDescriptor::hash_env({{"API_TOKEN", "s3cret"}});
+
+    ProofOfCompute proof;
+    proof.descriptor = ExecutionDescriptor{{"python3", "main.py"}, "/work", hash};
+    std::string json = proof.to_json();
+
+    EXPECT_NE(json.find("\"env_hash\": \"" + hash + "\""), std::string::npos) << json;
+    EXPECT_EQ(json.find("s3cret"), std::string::npos);
+    EXPECT_NE(hash, ExecutionDescriptor::hash_env({{"API_TOKEN", "other"}}));
+}
+
+TEST(ProofOfComputeTest, Version_NewProofsUseCurrentFormat) {
+    ProofGenerator gen;
+    gen.start_recording("job1", "code");
+    ProofOfCompute proof = gen.generate_proof("output", 1.0, 1000);
+
+    EXPECT_EQ(proof.proof_version, PROOF_VERSION);
+    EXPECT_NE(proof.to_json().find("\"proof_version\": " + std::to_string(PROOF_VERSION)),
+              std::string::npos);
+}
+
+TEST(ProofOfComputeTest, Version_EncodingDependsOnVersion) {
+    // Given: The same proof fields under each supported version
+    ProofOfCompute v1;
+    v1.job_id = "job1";
+    v1.cpu_time = 1.0;
+    v1.gpu_time = 0.0;
+    v1.memory_peak = 1000;
+    v1.syscall_count = 3;
+    v1.proof_version = 1;
+    ProofOfCompute v2 = v1;
+    v2.proof_version = 2;
+
+    // Then: Both encode, only v2 under a domain, and hash differently
+    ASSERT_TRUE(v1.canonical_bytes());
+    ASSERT_TRUE(v2.canonical_bytes());
+    EXPECT_EQ(*v1.canonical_bytes(), "job11010003");
+    EXPECT_EQ(v2.canonical_bytes()->rfind(PROOF_V2_HASH_DOMAIN, 0), 0u);
+    EXPECT_NE(v1.calculate_hash(), v2.calculate_hash());
+}
+
+TEST(ProofOfComputeTest, Version_V2SeparatesFields) {
+    // Given: Two proofs whose fields only differ in where one ends
+    ProofOfCompute a;
+    a.cpu_time = 0; a.gpu_time = 0; a.memory_peak = 0; a.syscall_count = 0;
+    a.proof_version = 2;
+    ProofOfCompute b = a;
+    a.job_id = "ab";  a.code_hash = "c";
+    b.job_id = "a";   b.code_hash = "bc";
+
+    // Then: v2 tells them apart, where v1's plain concatenation could not
+    EXPECT_NE(a.calculate_hash(), b.calculate_hash());
+    a.proof_version = 1;
+    b.proof_version = 1;
+    EXPECT_EQ(a.calculate_hash(), b.calculate_hash());
+}
+
+TEST(ProofOfComputeTest, Version_RejectsUnknownFutureVersion) {
+    // Given: A proof from a newer node
+    ExecutionTrace trace;
+    ProofOfCompute proof;
+    proof.syscall_count = 0;
+    proof.execution_hash = FileUtils::sha256_string("");
+    ASSERT_TRUE(proof.verify(trace));
+    proof.proof_version = PROOF_VERSION + 1;
+
+    // When: Encoding or verifying it
+    std::string error;
+    auto bytes = proof.canonical_bytes(&error);
+
+    // Then: It is refused clearly rather than hashed the wrong way
+    EXPECT_FALSE(bytes);
+    EXPECT_NE(error.find("Unsupported proof version"), std::string::npos) << error;
+    EXPECT_TRUE(proof.calculate_hash().empty());
+    EXPECT_FALSE(proof.verify(trace));
+}
+
+TEST(ProofOfComputeTest, WorkUnits_WeighsGpuAndMemory) {
+    // Given: A one-second CPU job with no GPU and no memory
+    ProofOfCompute cpu_job;
+    cpu_job.cpu_time = 1.0;
+    cpu_job.gpu_time = 0.0;
+    cpu_job.memory_peak = 0;
+
+    // Then: It is worth one work unit
+    EXPECT_DOUBLE_EQ(cpu_job.work_units(), 1.0);
+
+    // When: The same job also uses a GPU second and holds 10 GiB
+    ProofOfCompute gpu_job = cpu_job;
+    gpu_job.gpu_time = 1.0;
+    gpu_job.memory_peak = 10ull * 1024 * 1024 * 1024;
+
+    // Then: Each adds its weight
+    EXPECT_DOUBLE_EQ(gpu_job.work_units(),
+                     1.0 + GPU_SECOND_WORK_UNITS + GIB_SECOND_WORK_UNITS * 10.0);
+}
+
+TEST(ProofOfComputeTest, WorkUnits_LongJobOutweighsManyTrivialOnes) {
+    ProofOfCompute trivial;
+    trivial.cpu_time = 0.01;
+    trivial.gpu_time = 0.0;
+    trivial.memory_peak = 1024 * 1024;
+
+    ProofOfCompute training;
+    training.cpu_time = 3600.0;
+    training.gpu_time = 36000.0;
+    training.memory_peak = 16ull * 1024 * 1024 * 1024;
+
+    EXPECT_GT(training.work_units(), 10000 * trivial.work_units());
+}
+
 TEST_F(ProofTest, GeneratorBasicFlow) {
     std::string job_id = "test_job";
     std::string code = "print('hello world')";
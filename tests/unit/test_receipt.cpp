@@ -0,0 +1,130 @@
+#include <gtest/gtest.h>
+#include "receipt.h"
+#include "file_utils.h"
+
+namespace sandrun {
+namespace {
+
+class ExecutionReceiptTest : public ::testing::Test {
+protected:
+    void SetUp() override {
+        identity = WorkerIdentity::generate();
+        ASSERT_NE(identity, nullptr);
+
+        job.entrypoint = "main.py";
+        job.interpreter = "python3";
+        job.environment = "ml-basic";
+        job.args = {"--epochs", "5"};
+        job.code = "print('train')";
+
+        proof.job_id = "job-1";
+        proof.code_hash = FileUtils::sha256_string(job.code);
+        proof.input_hash = FileUtils::sha256_string("input");
+        proof.output_hash = FileUtils::sha256_string("output");
+        proof.execution_hash = FileUtils::sha256_string("trace");
+    }
+
+    std::unique_ptr<WorkerIdentity> identity;
+    JobDefinition job;
+    ProofOfCompute proof;
+};
+
+TEST_F(ExecutionReceiptTest, BuildProducesVerifiableReceipt) {
+    // Given: A job and the proof of its execution
+    // When: Building a receipt
+    auto receipt = ExecutionReceipt::build(job, proof, *identity);
+
+    // Then: It links every hash and verifies against the worker's key
+    ASSERT_TRUE(receipt);
+    EXPECT_EQ(receipt->job_hash, job.calculate_hash());
+    EXPECT_EQ(receipt->code_hash, proof.code_hash);
+    EXPECT_EQ(receipt->output_hash, proof.output_hash);
+    EXPECT_EQ(receipt->env_hash, ExecutionReceipt::calculate_env_hash(job));
+    EXPECT_EQ(receipt->worker_id, identity->get_worker_id());
+    EXPECT_TRUE(receipt->verify());
+}
+
+TEST_F(ExecutionReceiptTest, ReceiptIsReproducible) {
+    // Given: The same job and proof
+    // When: Building two receipts with the same key
+    auto first = ExecutionReceipt::build(job, proof, *identity);
+    auto second = ExecutionReceipt::build(job, proof, *identity);
+
+    // Then: They are byte-identical
+    ASSERT_TRUE(first && second);
+    EXPECT_EQ(first->to_json(), second->to_json());
+}
+
+TEST_F(ExecutionReceiptTest, TamperedReceiptFailsVerification) {
+    auto receipt = ExecutionReceipt::build(job, proof, *identity);
+    ASSERT_TRUE(receipt);
+
+    receipt->output_hash = FileUtils::sha256_string("forged output");
+
+    EXPECT_FALSE(receipt->verify());
+}
+
+TEST_F(ExecutionReceiptTest, ReceiptFromOtherWorkerFailsVerification) {
+    // Given: A receipt re-attributed to a different worker
+    auto receipt = ExecutionReceipt::build(job, proof, *identity);
+    ASSERT_TRUE(receipt);
+    auto other = WorkerIdentity::generate();
+    receipt->worker_id = other->get_worker_id();
+
+    // Then: The original signature no longer verifies
+    EXPECT_FALSE(receipt->verify());
+}
+
+TEST_F(ExecutionReceiptTest, RejectsProofForDifferentCode) {
+    // Given: A proof whose code hash doesn't match the job
+    proof.code_hash = FileUtils::sha256_string("other code");
+
+    // When: Building a receipt
+    std::string error;
+    auto receipt = ExecutionReceipt::build(job, proof, *identity, &error);
+
+    // Then: It fails with a reason
+    EXPECT_FALSE(receipt);
+    EXPECT_FALSE(error.empty());
+}
+
+TEST_F(ExecutionReceiptTest, SignsExecutionDescriptor) {
+    // Given: A proof recording the command the worker ran
+    proof.descriptor = ExecutionDescriptor{
+        {"/usr/bin/python3", "main.py", "--epochs", "5"}, "/work", ""};
+
+    // When: Building a receipt
+    auto receipt = ExecutionReceipt::build(job, proof, *identity);
+
+    // Then: The descriptor hash is signed with the rest
+    ASSERT_TRUE(receipt);
+    EXPECT_EQ(receipt->descriptor_hash, proof.descriptor->calculate_hash());
+    EXPECT_TRUE(receipt->verify());
+    receipt->descriptor_hash = "";
+    EXPECT_FALSE(receipt->verify());
+}
+
+TEST_F(ExecutionReceiptTest, RejectsDescriptorForDifferentCommand) {
+    // Given: A worker that quietly changed an argument
+    proof.descriptor = ExecutionDescriptor{
+        {"python3", "main.py", "--epochs", "1"}, "/work", ""};
+
+    // When: Building a receipt
+    std::string error;
+    auto receipt = ExecutionReceipt::build(job, proof, *identity, &error);
+
+    // Then: It is refused
+    EXPECT_FALSE(receipt);
+    EXPECT_NE(error.find("command"), std::string::npos) << error;
+}
+
+TEST_F(ExecutionReceiptTest, EnvHashReflectsEnvironment) {
+    JobDefinition other = job;
+    other.environment = "pytorch";
+
+    EXPECT_NE(ExecutionReceipt::calculate_env_hash(job),
+              ExecutionReceipt::calculate_env_hash(other));
+}
+
+} // namespace
+} // namespace sandrun
@@ -4,6 +4,7 @@
 #include <fstream>
 #include <filesystem>
 #include <thread>
+#include <csignal>
 
 namespace sandrun {
 namespace {
@@ -236,6 +237,84 @@ print(f'CUDA_VISIBLE_DEVICES: {cuda_device}')
     EXPECT_TRUE(result.output.find("CUDA_VISIBLE_DEVICES: 0") != std::string::npos);
 }
 
+TEST(GpuInfoTest, AvailablePartitionsPrefersSmallestFit) {
+    // Given: An A100 split into MIG slices, one of them busy
+    const size_t GB = 1024ULL * 1024 * 1024;
+    GpuInfo gpu;
+    gpu.partitions = {
+        {"MIG-a", 40 * GB, 80, false},
+        {"MIG-b", 20 * GB, 80, false},
+        {"MIG-c", 10 * GB, 80, true},
+        {"MIG-d", 20 * GB, 80, false},
+    };
+
+    // When: A job needs 10GB
+    GpuRequirements req;
+    req.min_memory_bytes = 10 * GB;
+    auto available = gpu.available_partitions(req);
+
+    // Then: Free slices that fit are offered smallest first, busy ones skipped
+    EXPECT_EQ(available, (std::vector<size_t>{1, 3, 0}));
+}
+
+TEST(GpuInfoTest, AvailablePartitionsChecksComputeCapability) {
+    const size_t GB = 1024ULL * 1024 * 1024;
+    GpuInfo gpu;
+    gpu.partitions = {
+        {"GPU-old", 16 * GB, 70, false},
+        {"GPU-new", 16 * GB, 90, false},
+    };
+
+    GpuRequirements req;
+    req.min_memory_bytes = 8 * GB;
+    req.min_compute_capability = 80;
+
+    EXPECT_EQ(gpu.available_partitions(req), (std::vector<size_t>{1}));
+
+    req.min_memory_bytes = 32 * GB;
+    EXPECT_TRUE(gpu.available_partitions(req).empty());
+}
+
+TEST(ClassifyExitTest, DistinguishesCrashesFromJobFailures) {
+    // Given/When/Then: Clean and nonzero exits are the job's own result
+    EXPECT_EQ(classify_exit(0, 0), FailureReason::NONE);
+    EXPECT_EQ(classify_exit(1, 0), FailureReason::NONZERO_EXIT);
+    EXPECT_EQ(classify_exit(139, 0), FailureReason::NONZERO_EXIT);  // Exited, even if 128+N
+
+    // Signal deaths are interpreter/runtime crashes, except seccomp kills
+    EXPECT_EQ(classify_exit(-1, SIGSEGV), FailureReason::RUNTIME_CRASH);
+    EXPECT_EQ(classify_exit(-1, SIGABRT), FailureReason::RUNTIME_CRASH);
+    EXPECT_EQ(classify_exit(-1, SIGSYS), FailureReason::SANDBOX_VIOLATION);
+
+    // And except kills at a resource limit, which the job ran into itself
+    EXPECT_EQ(classify_exit(-1, SIGKILL), FailureReason::RESOURCE_LIMIT);
+    EXPECT_EQ(classify_exit(-1, SIGXCPU), FailureReason::RESOURCE_LIMIT);
+    EXPECT_EQ(classify_exit(-1, SIGXFSZ), FailureReason::RESOURCE_LIMIT);
+}
+
+TEST(ClassifyExitTest, ExitStatusEncodesSignal) {
+    // Given/When/Then: Without a signal, 128+N and -N mean killed by N
+    EXPECT_EQ(classify_exit_status(0), FailureReason::NONE);
+    EXPECT_EQ(classify_exit_status(1), FailureReason::NONZERO_EXIT);
+    EXPECT_EQ(classify_exit_status(128), FailureReason::NONZERO_EXIT);
+    EXPECT_EQ(classify_exit_status(128 + SIGKILL), FailureReason::RESOURCE_LIMIT);
+    EXPECT_EQ(classify_exit_status(128 + SIGSEGV), FailureReason::RUNTIME_CRASH);
+    EXPECT_EQ(classify_exit_status(128 + SIGSYS), FailureReason::SANDBOX_VIOLATION);
+    EXPECT_EQ(classify_exit_status(-SIGXCPU), FailureReason::RESOURCE_LIMIT);
+    EXPECT_EQ(classify_exit_status(255), FailureReason::NONZERO_EXIT);
+}
+
+TEST(ClassifyExitTest, OnlyRuntimeCrashesAreRetryable) {
+    EXPECT_TRUE(is_retryable(FailureReason::RUNTIME_CRASH));
+    EXPECT_FALSE(is_retryable(FailureReason::NONZERO_EXIT));
+    EXPECT_FALSE(is_retryable(FailureReason::SANDBOX_VIOLATION));
+    EXPECT_FALSE(is_retryable(FailureReason::TIMEOUT));
+    EXPECT_FALSE(is_retryable(FailureReason::RESOURCE_LIMIT));
+    EXPECT_FALSE(is_retryable(FailureReason::CONTENT_POLICY));
+    EXPECT_EQ(failure_reason_to_string(FailureReason::CONTENT_POLICY), "content_policy");
+    EXPECT_EQ(failure_reason_to_string(FailureReason::RUNTIME_CRASH), "runtime_crash");
+}
+
 TEST_F(SandboxTest, MultipleInterpreters) {
     // Given: Different interpreters are available
     // When: Code is executed with each interpreter